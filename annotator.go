@@ -0,0 +1,30 @@
+package eg
+
+// Annotator notes errors with a fixed prefix, such as "[req 123]", so that a
+// request handler can mark every error it returns without threading the prefix
+// through each call.
+type Annotator struct {
+	prefix string
+}
+
+// NewAnnotator returns an Annotator that prepends prefix, followed by a space,
+// to every message.
+func NewAnnotator(prefix string) Annotator {
+	return Annotator{prefix: prefix}
+}
+
+// Note notes err with the prefixed message, as with Note.  The prefix is not
+// treated as part of the format string.  An empty msg is noted as the prefix
+// alone.
+func (a Annotator) Note(err error, msg string, args ...interface{}) error {
+	if err == nil {
+		return nil
+	}
+	if len(args) > 0 {
+		msg = sprintf(msg, args...)
+	}
+	if msg == "" {
+		return note(err, 1, a.prefix)
+	}
+	return note(err, 1, a.prefix+" "+msg)
+}
@@ -0,0 +1,29 @@
+package eg_test
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/natefinch/eg"
+)
+
+func TestAnnotator(t *testing.T) {
+	a := eg.NewAnnotator("[req 100%]")
+
+	first := a.Note(errors.New("timeout"), "loading user %d", 7)
+	second := a.Note(eg.Error("not found"), "loading order")
+
+	if first.Error() != "[req 100%] loading user 7: timeout" {
+		t.Errorf("unexpected first error %q", first.Error())
+	}
+	if second.Error() != "[req 100%] loading order: not found" {
+		t.Errorf("unexpected second error %q", second.Error())
+	}
+	if !strings.Contains(eg.Details(second), "TestAnnotator@") {
+		t.Errorf("expected the caller's location, got:\n%s", eg.Details(second))
+	}
+	if a.Note(nil, "loading") != nil {
+		t.Error("expected nil for a nil error")
+	}
+}
@@ -0,0 +1,140 @@
+package eg
+
+import (
+	"bytes"
+	"encoding"
+	"encoding/gob"
+	"errors"
+	"time"
+)
+
+var (
+	_ encoding.BinaryMarshaler   = (*Err)(nil)
+	_ encoding.BinaryUnmarshaler = (*Err)(nil)
+)
+
+// binErr is the binary representation of an error chain, outermost first.  The
+// chain is flattened, rather than nested, so that decoding never recurses.
+type binErr struct {
+	Layers []binLayer
+}
+
+// binLayer is one error in a chain.  Errors other than Errs keep only their
+// message.
+type binLayer struct {
+	Foreign     bool
+	Message     string
+	Location    location
+	Annotations []binAnnotation
+	Code        string
+	RequestID   string
+	Severity    Severity
+	UserMessage string
+	Args        []string
+	Goroutine   uint64
+	ID          string
+	Duration    time.Duration
+}
+
+// binAnnotation is the binary representation of an annotation.  gob skips
+// unexported fields, including annotation's embedded location.
+type binAnnotation struct {
+	Message   string
+	Location  location
+	Goroutine uint64
+	Event     string
+	Args      []string
+}
+
+// MarshalBinary implements encoding.BinaryMarshaler, encoding the error and its
+// chain with encoding/gob.  Each Err in the chain keeps its message, location,
+// annotations, code, request ID, severity, user message, args, ID, and
+// duration; other errors keep only their message.  Stacks, context values, and
+// annotation fields are not encoded.
+// A chain that refers back to an earlier error ends at the repeat.
+func (e *Err) MarshalBinary() ([]byte, error) {
+	if e == nil {
+		return nil, errors.New("eg: MarshalBinary of nil *Err")
+	}
+	b := binErr{}
+	seen := map[*Err]bool{}
+	for err := error(e); err != nil; {
+		c, ok := err.(*Err)
+		if !ok {
+			b.Layers = append(b.Layers, binLayer{Foreign: true, Message: err.Error()})
+			break
+		}
+		if c == nil {
+			break
+		}
+		if seen[c] {
+			break
+		}
+		seen[c] = true
+		annotations := make([]binAnnotation, len(c.Annotations))
+		for x, a := range c.Annotations {
+			annotations[x] = binAnnotation{a.Message, a.location, a.Goroutine, a.Event, a.Args}
+		}
+		b.Layers = append(b.Layers, binLayer{
+			Message:     c.Message,
+			Location:    c.Location,
+			Annotations: annotations,
+			Code:        c.Code,
+			RequestID:   c.RequestID,
+			Severity:    c.Severity,
+			UserMessage: c.UserMessage,
+			Args:        c.Args,
+			Goroutine:   c.Goroutine,
+			ID:          c.ID,
+			Duration:    c.Duration,
+		})
+		err = c.CauseErr
+	}
+	buf := &bytes.Buffer{}
+	if err := gob.NewEncoder(buf).Encode(b); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// UnmarshalBinary implements encoding.BinaryUnmarshaler, decoding an error
+// encoded by MarshalBinary into e.  Errors in the chain that were not Errs are
+// decoded as plain errors with the same message.
+func (e *Err) UnmarshalBinary(data []byte) error {
+	b := binErr{}
+	if err := gob.NewDecoder(bytes.NewReader(data)).Decode(&b); err != nil {
+		return err
+	}
+	if len(b.Layers) == 0 || b.Layers[0].Foreign {
+		return errors.New("eg: binary data does not hold an Err")
+	}
+	var cause error
+	for x := len(b.Layers) - 1; x >= 0; x-- {
+		l := b.Layers[x]
+		if l.Foreign {
+			cause = errors.New(l.Message)
+			continue
+		}
+		var annotations []annotation
+		for _, a := range l.Annotations {
+			annotations = append(annotations, annotation{Message: a.Message, location: a.Location, Goroutine: a.Goroutine, Event: a.Event, Args: a.Args})
+		}
+		c := &Err{
+			Message:     l.Message,
+			Location:    l.Location,
+			Annotations: annotations,
+			Code:        l.Code,
+			RequestID:   l.RequestID,
+			Severity:    l.Severity,
+			UserMessage: l.UserMessage,
+			Args:        l.Args,
+			Goroutine:   l.Goroutine,
+			ID:          l.ID,
+			Duration:    l.Duration,
+			CauseErr:    cause,
+		}
+		cause = c
+	}
+	*e = *cause.(*Err)
+	return nil
+}
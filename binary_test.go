@@ -0,0 +1,39 @@
+package eg_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/natefinch/eg"
+)
+
+func TestBinaryRoundTrip(t *testing.T) {
+	root := eg.MaskCode(errors.New("connection refused"), "NOT_FOUND", "dialing db")
+	err := eg.Note(eg.Note(eg.WithArgs(root, "db:5432"), "loading user"), "handling request")
+	orig := err.(*eg.Err)
+
+	data, marshalErr := orig.MarshalBinary()
+	if marshalErr != nil {
+		t.Fatal(marshalErr)
+	}
+	decoded := &eg.Err{}
+	if unmarshalErr := decoded.UnmarshalBinary(data); unmarshalErr != nil {
+		t.Fatal(unmarshalErr)
+	}
+
+	if decoded.Error() != orig.Error() {
+		t.Errorf("expected Error() %q, got %q", orig.Error(), decoded.Error())
+	}
+	if decoded.Details() != orig.Details() {
+		t.Errorf("expected Details:\n%s\ngot:\n%s", orig.Details(), decoded.Details())
+	}
+	if decoded.StackTrace() != "" {
+		t.Errorf("expected no stack after decoding, got:\n%s", decoded.StackTrace())
+	}
+}
+
+func TestUnmarshalBinaryInvalid(t *testing.T) {
+	if err := (&eg.Err{}).UnmarshalBinary([]byte("garbage")); err == nil {
+		t.Error("expected an error decoding invalid data")
+	}
+}
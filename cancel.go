@@ -0,0 +1,19 @@
+package eg
+
+import (
+	"context"
+	"errors"
+)
+
+// IsCanceled reports whether err was caused by a canceled context, such as a
+// client disconnecting, no matter how many times it has been wrapped.
+func IsCanceled(err error) bool {
+	return errors.Is(err, context.Canceled)
+}
+
+// IsDeadlineExceeded reports whether err was caused by a context whose
+// deadline passed, such as a slow backend, no matter how many times it has been
+// wrapped.
+func IsDeadlineExceeded(err error) bool {
+	return errors.Is(err, context.DeadlineExceeded)
+}
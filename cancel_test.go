@@ -0,0 +1,30 @@
+package eg_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/natefinch/eg"
+)
+
+func TestIsCanceled(t *testing.T) {
+	err := eg.Note(eg.Note(context.Canceled, "querying"), "handling request")
+
+	if !eg.IsCanceled(err) {
+		t.Error("expected IsCanceled to be true")
+	}
+	if eg.IsDeadlineExceeded(err) {
+		t.Error("expected IsDeadlineExceeded to be false")
+	}
+}
+
+func TestIsDeadlineExceeded(t *testing.T) {
+	err := eg.Note(eg.Note(context.DeadlineExceeded, "querying"), "handling request")
+
+	if !eg.IsDeadlineExceeded(err) {
+		t.Error("expected IsDeadlineExceeded to be true")
+	}
+	if eg.IsCanceled(err) {
+		t.Error("expected IsCanceled to be false")
+	}
+}
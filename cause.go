@@ -0,0 +1,148 @@
+package eg
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// CauseResolver returns the cause of err.  If it doesn't know how to find a
+// cause for err, ok should be false.
+type CauseResolver func(err error) (cause error, ok bool)
+
+// resolvers are consulted in order to find the cause of an error.  Unwrap
+// takes precedence over Effect, so that for errors implementing both, Cause
+// agrees with errors.Unwrap.
+var resolvers = []CauseResolver{unwrapCause, effectCause}
+
+// RegisterCauseResolver adds a resolver used by Cause and any functions that
+// walk an error's chain, so they can follow error types that expose their
+// cause in a non-standard way.  Resolvers for Unwrap() error and for Effect are
+// built in and consulted first, in that order.  RegisterCauseResolver should
+// be called during initialization.
+func RegisterCauseResolver(r CauseResolver) {
+	resolvers = append(resolvers, r)
+}
+
+// effectCause resolves the cause of errors implementing Effect.
+func effectCause(err error) (error, bool) {
+	if e, ok := err.(Effect); ok {
+		return e.Cause(), true
+	}
+	return nil, false
+}
+
+// unwrapCause resolves the cause of errors implementing Unwrap() error, such
+// as those created by fmt.Errorf with %w.
+func unwrapCause(err error) (error, bool) {
+	if u, ok := err.(interface{ Unwrap() error }); ok {
+		return u.Unwrap(), true
+	}
+	return nil, false
+}
+
+// RootCause returns the deepest error in err's chain.
+func RootCause(err error) error {
+	root := err
+	walk(err, func(err error) bool {
+		root = err
+		return true
+	})
+	return root
+}
+
+// ReplaceRoot returns a copy of err's chain with its root cause swapped for
+// newRoot, keeping every outer layer and annotation, such as for replacing a
+// driver-specific error with a neutral one at a boundary.  It follows the chain
+// through Errs only, copying each as MapMessages does, so err itself is left
+// unchanged.  If the deepest Err has a cause, that cause is replaced.
+// Otherwise the deepest Err is itself the root, and it is replaced by a copy
+// without its message or location, keeping its annotations and other fields,
+// such as its code, with newRoot as the cause.  If err is not an Err,
+// newRoot is returned.
+func ReplaceRoot(err, newRoot error) error {
+	e, ok := err.(*Err)
+	if !ok || e == nil {
+		return newRoot
+	}
+	c := e.Clone()
+	if e.CauseErr == nil {
+		// The old root's message and location describe the error being
+		// replaced, so only its annotations and other fields are kept.
+		c.Message = ""
+		c.Location = location{}
+		c.CauseErr = newRoot
+		return c
+	}
+	if cause, ok := e.CauseErr.(*Err); ok && cause != nil {
+		c.CauseErr = ReplaceRoot(cause, newRoot)
+	} else {
+		c.CauseErr = newRoot
+	}
+	return c
+}
+
+// SameRoot reports whether a and b stem from the same underlying problem: their
+// root causes, as found by RootCause, are either the same error or Errs with
+// the same message created at the same location, regardless of annotations.
+// Errors that aren't Errs have no location, so they match by message alone.
+// It is false if either error is nil.
+func SameRoot(a, b error) bool {
+	ra, rb := RootCause(a), RootCause(b)
+	if ra == nil || rb == nil {
+		return false
+	}
+	if t := reflect.TypeOf(ra); t == reflect.TypeOf(rb) && t.Comparable() && ra == rb {
+		return true
+	}
+	ea, aok := ra.(*Err)
+	eb, bok := rb.(*Err)
+	if aok && bok {
+		return ea.Message == eb.Message && ea.Location == eb.Location
+	}
+	return !aok && !bok && ra.Error() == rb.Error()
+}
+
+// RangeCauses calls fn for each error in err's chain, starting with err itself
+// and ending with its root cause, following both Effect's Cause and Unwrap.
+// Iteration stops if fn returns false.  Unlike collecting the chain into a
+// slice, it does not allocate.
+func RangeCauses(err error, fn func(error) bool) {
+	walk(err, fn)
+}
+
+// OriginatedIn reports whether the deepest Err in err's chain was created in a
+// function whose name ends with suffix, such as "mypkg.LoadConfig".  It is
+// intended for tests asserting where an error was created.
+func OriginatedIn(err error, suffix string) bool {
+	var root *Err
+	walk(err, func(err error) bool {
+		if e, ok := err.(*Err); ok {
+			root = e
+		}
+		return true
+	})
+	return root != nil && strings.HasSuffix(root.Location.Function, suffix)
+}
+
+// Validate checks that err's chain doesn't contain the same Err more than once,
+// such as when an error is accidentally made its own cause.  Such chains loop
+// forever when walked.  It returns an error describing the problem, or nil.
+// It is intended for use in tests.
+func Validate(err error) error {
+	seen := map[*Err]int{}
+	depth := 0
+	var problem error
+	walk(err, func(err error) bool {
+		if e, ok := err.(*Err); ok {
+			if prev, ok := seen[e]; ok {
+				problem = fmt.Errorf("eg: error %q at depth %d is the same *Err as at depth %d", e.Message, depth, prev)
+				return false
+			}
+			seen[e] = depth
+		}
+		depth++
+		return true
+	})
+	return problem
+}
@@ -0,0 +1,238 @@
+package eg_test
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/natefinch/eg"
+)
+
+// innerError exposes its cause through a non-standard method.
+type innerError struct {
+	inner error
+}
+
+func (e innerError) Error() string     { return "inner: " + e.inner.Error() }
+func (e innerError) InnerError() error { return e.inner }
+
+func init() {
+	eg.RegisterCauseResolver(func(err error) (error, bool) {
+		if e, ok := err.(innerError); ok {
+			return e.InnerError(), true
+		}
+		return nil, false
+	})
+}
+
+func TestRootCauseCustomResolver(t *testing.T) {
+	root := errors.New("root")
+	err := eg.Note(innerError{fmt.Errorf("wrapped: %w", root)}, "outer")
+
+	if got := eg.RootCause(err); got != root {
+		t.Errorf("expected root cause %v, got %v", root, got)
+	}
+}
+
+func TestRootCause(t *testing.T) {
+	root := eg.Error("root")
+	if got := eg.RootCause(eg.Note(root, "annotated")); got != root {
+		t.Errorf("expected root cause %v, got %v", root, got)
+	}
+	if got := eg.RootCause(nil); got != nil {
+		t.Errorf("expected nil, got %v", got)
+	}
+}
+
+func loadConfig() error {
+	return eg.Error("no config")
+}
+
+func TestOriginatedIn(t *testing.T) {
+	err := eg.Note(fmt.Errorf("wrapped: %w", loadConfig()), "starting")
+
+	if !eg.OriginatedIn(err, "eg_test.loadConfig") {
+		t.Error("expected error to originate in loadConfig")
+	}
+	if eg.OriginatedIn(err, "eg_test.TestOriginatedIn") {
+		t.Error("expected error not to originate in TestOriginatedIn")
+	}
+	if eg.OriginatedIn(errors.New("plain"), "") {
+		t.Error("expected plain error not to originate anywhere")
+	}
+}
+
+func TestValidate(t *testing.T) {
+	e := eg.Error("aliased")
+	e.CauseErr = fmt.Errorf("wrapped: %w", &eg.Err{Message: "middle", CauseErr: e})
+
+	err := eg.Validate(e)
+	if err == nil {
+		t.Fatal("expected aliasing to be reported")
+	}
+	expected := `eg: error "aliased" at depth 3 is the same *Err as at depth 0`
+	if err.Error() != expected {
+		t.Errorf("expected %q, got %q", expected, err.Error())
+	}
+}
+
+func TestValidateOK(t *testing.T) {
+	if err := eg.Validate(eg.Note(eg.Error("root"), "fine")); err != nil {
+		t.Errorf("expected no problem, got %v", err)
+	}
+}
+
+// bothError reports different causes through Effect and Unwrap.
+type bothError struct {
+	cause, unwrapped error
+}
+
+func (e bothError) Error() string { return "both" }
+func (e bothError) Cause() error  { return e.cause }
+func (e bothError) Unwrap() error { return e.unwrapped }
+
+func TestUnwrapTakesPrecedence(t *testing.T) {
+	unwrapped := errors.New("unwrapped")
+	err := eg.Note(bothError{cause: errors.New("cause"), unwrapped: unwrapped}, "outer")
+
+	inner := err.(*eg.Err).CauseErr
+	if cause, _ := eg.Cause(inner); cause != errors.Unwrap(inner) {
+		t.Errorf("expected Cause to agree with errors.Unwrap, got %v", cause)
+	}
+	if root := eg.RootCause(err); root != unwrapped {
+		t.Errorf("expected RootCause to follow Unwrap, got %v", root)
+	}
+	if !errors.Is(err, unwrapped) {
+		t.Error("expected errors.Is to follow Unwrap")
+	}
+}
+
+// effectError exposes its cause only through Effect's Cause method.
+type effectError struct {
+	cause error
+}
+
+func (e effectError) Error() string { return "effect" }
+func (e effectError) Cause() error  { return e.cause }
+
+func TestRangeCauses(t *testing.T) {
+	root := errors.New("root")
+	target := effectError{cause: root}
+	err := eg.Note(fmt.Errorf("wrapped: %w", target), "outer")
+
+	var seen []error
+	eg.RangeCauses(err, func(err error) bool {
+		seen = append(seen, err)
+		return err != target
+	})
+	if len(seen) != 3 || seen[2] != target {
+		t.Errorf("expected to stop at the target after 3 errors, got %v", seen)
+	}
+
+	n := 0
+	eg.RangeCauses(err, func(error) bool { n++; return true })
+	if n != 4 {
+		t.Errorf("expected to visit 4 errors through Unwrap and Cause, got %d", n)
+	}
+}
+
+func BenchmarkRangeCauses(b *testing.B) {
+	err := deepChain(20)
+	fn := func(error) bool { return true }
+
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		eg.RangeCauses(err, fn)
+	}
+}
+
+func openConfig() error {
+	return eg.Error("config not found")
+}
+
+func TestSameRoot(t *testing.T) {
+	root := errors.New("connection reset")
+	a := eg.Note(root, "querying users")
+	b := eg.Note(fmt.Errorf("retrying: %w", root), "querying orders")
+	if !eg.SameRoot(a, b) {
+		t.Error("expected errors wrapping the same root to match")
+	}
+
+	// Separate errors created by the same code match.
+	if !eg.SameRoot(eg.Note(openConfig(), "starting"), openConfig()) {
+		t.Error("expected roots created at the same location to match")
+	}
+}
+
+func TestSameRootIndependent(t *testing.T) {
+	a := eg.Note(eg.Error("connection reset"), "querying users")
+	b := eg.Note(eg.Error("connection reset"), "querying orders")
+	if eg.SameRoot(a, b) {
+		t.Error("expected roots created at different locations not to match")
+	}
+	if eg.SameRoot(a, nil) {
+		t.Error("expected a nil error not to match")
+	}
+}
+
+func TestReplaceRoot(t *testing.T) {
+	driver := errors.New("pq: connection refused")
+	neutral := errors.New("database unavailable")
+	inner := eg.Note(eg.Error("query failed").SetCause(driver), "querying users")
+	err := eg.Note(eg.Error("loading profile").SetCause(inner), "handling request")
+
+	before := err.Error()
+
+	replaced := eg.ReplaceRoot(err, neutral)
+	if want := "handling request: loading profile: querying users: query failed: database unavailable"; replaced.Error() != want {
+		t.Errorf("expected %q, got %q", want, replaced.Error())
+	}
+	if errors.Is(replaced, driver) || !errors.Is(replaced, neutral) {
+		t.Error("expected the new root to replace the old one")
+	}
+	if err.Error() != before || !errors.Is(err, driver) || inner.Error() != "querying users: query failed: pq: connection refused" {
+		t.Errorf("expected the original chain to be unchanged, got %q", err.Error())
+	}
+}
+
+func TestReplaceRootItself(t *testing.T) {
+	root := eg.Note(eg.Error("pq: connection refused"), "querying users")
+	err := eg.Note(eg.Error("loading profile").SetCause(root), "handling request")
+	neutral := errors.New("database unavailable")
+
+	replaced := eg.ReplaceRoot(err, neutral)
+	if want := "handling request: loading profile: querying users: database unavailable"; replaced.Error() != want {
+		t.Errorf("expected %q, got %q", want, replaced.Error())
+	}
+	if want := "handling request: loading profile: querying users: pq: connection refused"; err.Error() != want {
+		t.Errorf("expected the original chain to be unchanged, got %q", err.Error())
+	}
+	if root.Error() != "querying users: pq: connection refused" {
+		t.Errorf("expected the old root to be unchanged, got %q", root.Error())
+	}
+	if got := eg.ReplaceRoot(errors.New("plain"), neutral); got != neutral {
+		t.Errorf("expected the new root for a plain error, got %v", got)
+	}
+}
+
+func TestReplaceRootKeepsFields(t *testing.T) {
+	root := eg.WithRequestID(eg.MaskCode(errors.New("no rows"), "NOT_FOUND", "pq"), "req-1")
+	err := eg.Note(eg.Error("loading profile").SetCause(eg.Note(root, "querying users")), "handling request")
+
+	replaced := eg.ReplaceRoot(err, errors.New("not found"))
+	if code, _ := eg.Code(replaced); code != "NOT_FOUND" {
+		t.Errorf("expected the old root's code to be kept, got %q", code)
+	}
+	if id, _ := eg.RequestID(replaced); id != "req-1" {
+		t.Errorf("expected the old root's request ID to be kept, got %q", id)
+	}
+	for _, line := range strings.Split(eg.Details(replaced), "\n") {
+		if line == "" || strings.HasSuffix(line, "] ") {
+			t.Errorf("expected no line for the replaced root's message, got:\n%s", eg.Details(replaced))
+		}
+	}
+	if old, _ := eg.AsErr(eg.RootCause(err)); old.Message != "pq: no rows" {
+		t.Errorf("expected the old root to be unchanged, got %q", old.Message)
+	}
+}
@@ -0,0 +1,38 @@
+package eg
+
+// MaskCode returns a masked error, as with Mask, that carries the given code.
+// The code is safe to expose across a public boundary, letting clients branch
+// on it without gaining access to the original error.
+func MaskCode(err error, code, msg string, args ...interface{}) error {
+	if err == nil {
+		return nil
+	}
+	ret := mask(err, 1, msg, args...)
+	ret.Code = code
+	return created(ret)
+}
+
+// Code returns the code of the nearest Err in err's chain that has one.  If no
+// code is found, ok will be false.
+func Code(err error) (code string, ok bool) {
+	walk(err, func(err error) bool {
+		if e, isErr := err.(*Err); isErr && e.Code != "" {
+			code, ok = e.Code, true
+		}
+		return !ok
+	})
+	return code, ok
+}
+
+// AllCodes returns every code attached to an Err in err's chain, from the
+// outermost error to the root.
+func AllCodes(err error) []string {
+	codes := []string{}
+	walk(err, func(err error) bool {
+		if e, ok := err.(*Err); ok && e.Code != "" {
+			codes = append(codes, e.Code)
+		}
+		return true
+	})
+	return codes
+}
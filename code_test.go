@@ -0,0 +1,57 @@
+package eg_test
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/natefinch/eg"
+)
+
+func TestMaskCode(t *testing.T) {
+	orig := errors.New("connection refused")
+	err := eg.MaskCode(orig, "unavailable", "service down")
+
+	code, ok := eg.Code(err)
+	if !ok || code != "unavailable" {
+		t.Errorf("expected code %q, got %q (ok=%v)", "unavailable", code, ok)
+	}
+	if cause, _ := eg.Cause(err); cause != nil {
+		t.Errorf("expected no cause, got %v", cause)
+	}
+	if errors.Is(err, orig) {
+		t.Error("expected original error to be unreachable")
+	}
+}
+
+func TestMaskCodeNil(t *testing.T) {
+	if err := eg.MaskCode(nil, "unavailable", "service down"); err != nil {
+		t.Errorf("expected nil, got %v", err)
+	}
+}
+
+func TestAllCodes(t *testing.T) {
+	root := &eg.Err{Message: "no rows", Code: "not_found"}
+	err := &eg.Err{Message: "loading user", CauseErr: eg.Note(root, "querying"), Code: "user_missing"}
+
+	codes := eg.AllCodes(eg.Note(err, "handling request"))
+	if len(codes) != 2 || codes[0] != "user_missing" || codes[1] != "not_found" {
+		t.Errorf("expected [user_missing not_found], got %q", codes)
+	}
+}
+
+func TestMaskKeepsCode(t *testing.T) {
+	orig := eg.Note(eg.MaskCode(errors.New(`pq: relation "users" does not exist`), "NOT_FOUND", "querying"), "loading user")
+
+	for _, err := range []error{eg.Mask(orig, "lookup failed"), eg.MaskType(orig)} {
+		if code, ok := eg.Code(err); !ok || code != "NOT_FOUND" {
+			t.Errorf("expected the code to survive masking, got %q (ok=%v)", code, ok)
+		}
+		if errors.Is(err, orig) {
+			t.Error("expected the original error to be unreachable")
+		}
+	}
+	if err := eg.MaskType(orig); strings.Contains(eg.Details(err), "pq:") {
+		t.Errorf("expected the original message to be hidden, got:\n%s", eg.Details(err))
+	}
+}
@@ -0,0 +1,38 @@
+package eg
+
+import (
+	"os"
+)
+
+// IsTerminal reports whether colored output should be produced by
+// ColorDetails.  By default it reports whether standard output is a terminal.
+// Replace it to match wherever the details will be written.
+var IsTerminal = func() bool {
+	fi, err := os.Stdout.Stat()
+	return err == nil && fi.Mode()&os.ModeCharDevice != 0
+}
+
+// ColorDetails returns the error's details, as with Details, with ANSI colors:
+// locations in gray, messages in white, and error codes in red.  If
+// IsTerminal reports false, the plain details are returned.
+func ColorDetails(err error) string {
+	if err == nil {
+		return ""
+	}
+	if !IsTerminal() {
+		return Details(err)
+	}
+	if e, ok := err.(*Err); ok && e != nil {
+		return e.header() + e.details(ansi)
+	}
+	return ansi.message + Details(err) + ansiReset
+}
+
+const ansiReset = "\x1b[0m"
+
+// ansi colors locations gray, messages white, and codes red.
+var ansi = &style{
+	location: "\x1b[90m",
+	message:  "\x1b[97m",
+	code:     "\x1b[31m",
+}
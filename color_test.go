@@ -0,0 +1,41 @@
+package eg_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/natefinch/eg"
+)
+
+func withTerminal(isTerminal bool) func() {
+	old := eg.IsTerminal
+	eg.IsTerminal = func() bool { return isTerminal }
+	return func() { eg.IsTerminal = old }
+}
+
+func TestColorDetails(t *testing.T) {
+	defer withTerminal(true)()
+
+	err := &eg.Err{Message: "not found", Code: "missing"}
+	details := eg.ColorDetails(eg.Note(err, "loading"))
+
+	for _, code := range []string{"\x1b[90m", "\x1b[97m", "\x1b[31m[missing]"} {
+		if !strings.Contains(details, code) {
+			t.Errorf("expected %q in colored details, got %q", code, details)
+		}
+	}
+}
+
+func TestColorDetailsDisabled(t *testing.T) {
+	defer withTerminal(false)()
+
+	err := eg.Note(&eg.Err{Message: "not found", Code: "missing"}, "loading")
+	details := eg.ColorDetails(err)
+
+	if strings.Contains(details, "\x1b[") {
+		t.Errorf("expected no color codes, got %q", details)
+	}
+	if details != eg.Details(err) {
+		t.Errorf("expected plain details %q, got %q", eg.Details(err), details)
+	}
+}
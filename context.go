@@ -0,0 +1,129 @@
+package eg
+
+import (
+	"fmt"
+)
+
+// ContextKey is a typed key for a contextual value attached to an error, so
+// that values are retrieved with their static type.  Each key made by
+// NewContextKey is distinct, even from another key with the same name; the name
+// is only used to show the value in Context and Fields.
+type ContextKey[T any] struct {
+	id *keyID
+}
+
+// keyID is the identity of a ContextKey.
+type keyID struct {
+	name string
+}
+
+// NewContextKey returns a key for values of type T with the given name.
+func NewContextKey[T any](name string) ContextKey[T] {
+	return ContextKey[T]{&keyID{name}}
+}
+
+// String returns the key's name.
+func (k ContextKey[T]) String() string {
+	if k.id == nil {
+		return ""
+	}
+	return k.id.name
+}
+
+// SetValue attaches value to err under key.  If err is not an Err, it is
+// wrapped in one.  The value is also added to err's Context under the key's
+// name.
+func SetValue[T any](err error, key ContextKey[T], value T) error {
+	if err == nil {
+		return nil
+	}
+	e := toErr(err, 1)
+	if e.Context == nil {
+		e.Context = map[string]interface{}{}
+	}
+	if e.values == nil {
+		e.values = map[*keyID]interface{}{}
+	}
+	e.Context[key.String()] = value
+	e.values[key.id] = value
+	return reported(e, err)
+}
+
+// Value returns the value stored under key by the nearest Err in err's chain
+// that has one.  If no value of type T is found, ok will be false.
+func Value[T any](err error, key ContextKey[T]) (value T, ok bool) {
+	walk(err, func(err error) bool {
+		if e, isErr := err.(*Err); isErr {
+			value, ok = e.values[key.id].(T)
+		}
+		return !ok
+	})
+	return value, ok
+}
+
+// Context returns the contextual values attached to every Err in err's chain,
+// merged into a single map.  If the same key is set at more than one level, the
+// outermost (most recently attached) value wins.  It returns nil if there are
+// no values.
+func Context(err error) map[string]interface{} {
+	layers := []*Err{}
+	walk(err, func(err error) bool {
+		if e, ok := err.(*Err); ok && len(e.Context) > 0 {
+			layers = append(layers, e)
+		}
+		return true
+	})
+	if len(layers) == 0 {
+		return nil
+	}
+	ctx := map[string]interface{}{}
+	for x := len(layers) - 1; x >= 0; x-- {
+		for k, v := range layers[x].Context {
+			ctx[k] = v
+		}
+	}
+	return ctx
+}
+
+// Fields returns all the metadata attached across err's chain as a single map,
+// ready to pass to a structured logger.  It holds the merged Context along
+// with "code" and "request_id" entries for the nearest code and request ID,
+// which take precedence over context values of the same name.
+func Fields(err error) map[string]interface{} {
+	fields := Context(err)
+	if fields == nil {
+		fields = map[string]interface{}{}
+	}
+	if code, ok := Code(err); ok {
+		fields["code"] = code
+	}
+	if id, ok := RequestID(err); ok {
+		fields["request_id"] = id
+	}
+	return fields
+}
+
+// Notekv annotates err with msg, as with Note, and attaches kvs, alternating
+// keys and values as with log/slog, to its context.  Keys are formatted with
+// %v.  A final key without a value is given the value "(MISSING)".
+func Notekv(err error, msg string, kvs ...interface{}) error {
+	if err == nil {
+		return nil
+	}
+	noted, wrapped := annotateAt(err, 1, locate(1), msg)
+	e := toErr(noted, 1)
+	if len(kvs) > 0 && e.Context == nil {
+		e.Context = map[string]interface{}{}
+	}
+	for x := 0; x < len(kvs); x += 2 {
+		var v interface{} = "(MISSING)"
+		if x+1 < len(kvs) {
+			v = kvs[x+1]
+		}
+		e.Context[fmt.Sprint(kvs[x])] = v
+	}
+	if wrapped != nil {
+		created(wrapped)
+	}
+	return reported(e, noted)
+}
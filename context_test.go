@@ -0,0 +1,127 @@
+package eg_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/natefinch/eg"
+)
+
+type user struct {
+	ID   int
+	Name string
+}
+
+var (
+	attemptsKey = eg.NewContextKey[int]("attempts")
+	userKey     = eg.NewContextKey[user]("user")
+)
+
+func TestContextValues(t *testing.T) {
+	err := eg.SetValue(eg.Error("root"), attemptsKey, 3)
+	err = eg.SetValue(eg.Note(err, "retrying"), userKey, user{ID: 7, Name: "bob"})
+	err = eg.Note(err, "handling request")
+
+	attempts, ok := eg.Value(err, attemptsKey)
+	if !ok || attempts != 3 {
+		t.Errorf("expected 3 attempts, got %d (ok=%v)", attempts, ok)
+	}
+	u, ok := eg.Value(err, userKey)
+	if !ok || u != (user{ID: 7, Name: "bob"}) {
+		t.Errorf("expected user bob, got %v (ok=%v)", u, ok)
+	}
+}
+
+func TestContextValueMissing(t *testing.T) {
+	err := eg.SetValue(eg.Error("root"), attemptsKey, 3)
+
+	if _, ok := eg.Value(err, eg.NewContextKey[string]("attempts")); ok {
+		t.Error("expected a value of the wrong type not to be found")
+	}
+	if _, ok := eg.Value(err, userKey); ok {
+		t.Error("expected a missing value not to be found")
+	}
+}
+
+func TestContextKeysWithSameName(t *testing.T) {
+	// Two packages may each define a key with the same name.
+	idKey := eg.NewContextKey[int]("id")
+	otherIDKey := eg.NewContextKey[int]("id")
+
+	err := eg.SetValue(eg.SetValue(eg.Error("root"), idKey, 1), otherIDKey, 2)
+
+	if v, ok := eg.Value(err, idKey); !ok || v != 1 {
+		t.Errorf("expected 1, got %d (ok=%v)", v, ok)
+	}
+	if v, ok := eg.Value(err, otherIDKey); !ok || v != 2 {
+		t.Errorf("expected 2, got %d (ok=%v)", v, ok)
+	}
+	if v, ok := eg.Value(err.(*eg.Err).Clone(), idKey); !ok || v != 1 {
+		t.Errorf("expected the clone to keep the value, got %d (ok=%v)", v, ok)
+	}
+}
+
+func TestContextOutermostWins(t *testing.T) {
+	nameKey := eg.NewContextKey[string]("name")
+
+	root := eg.SetValue(eg.SetValue(eg.Error("root"), nameKey, "inner"), attemptsKey, 1)
+	err := eg.SetValue(&eg.Err{Message: "outer", CauseErr: root}, nameKey, "outer")
+
+	ctx := eg.Context(err)
+	if ctx["name"] != "outer" {
+		t.Errorf("expected outermost value to win, got %v", ctx["name"])
+	}
+	if ctx["attempts"] != 1 {
+		t.Errorf("expected inner values to be kept, got %v", ctx["attempts"])
+	}
+	if v, _ := eg.Value(err, nameKey); v != ctx["name"] {
+		t.Errorf("expected Value to agree with Context, got %q", v)
+	}
+}
+
+func TestContextNone(t *testing.T) {
+	if ctx := eg.Context(eg.Error("root")); ctx != nil {
+		t.Errorf("expected nil, got %v", ctx)
+	}
+}
+
+func TestFields(t *testing.T) {
+	root := eg.WithRequestID(eg.SetValue(eg.Error("root"), attemptsKey, 3), "req-1")
+	err := eg.SetValue(&eg.Err{Message: "outer", CauseErr: root, Code: "busy"}, userKey, user{ID: 7})
+
+	fields := eg.Fields(eg.Note(err, "handling"))
+	expected := map[string]interface{}{
+		"attempts":   3,
+		"user":       user{ID: 7},
+		"code":       "busy",
+		"request_id": "req-1",
+	}
+	if len(fields) != len(expected) {
+		t.Errorf("expected %v, got %v", expected, fields)
+	}
+	for k, v := range expected {
+		if fields[k] != v {
+			t.Errorf("expected %s=%v, got %v", k, v, fields[k])
+		}
+	}
+}
+
+func TestNotekv(t *testing.T) {
+	err := eg.Notekv(eg.Error("root"), "querying", "table", "users", "attempts", 3)
+
+	if err.Error() != "querying: root" {
+		t.Errorf("expected annotation, got %q", err.Error())
+	}
+	ctx := eg.Context(err)
+	if ctx["table"] != "users" || ctx["attempts"] != 3 || len(ctx) != 2 {
+		t.Errorf("expected table and attempts fields, got %v", ctx)
+	}
+}
+
+func TestNotekvOdd(t *testing.T) {
+	err := eg.Notekv(errors.New("root"), "querying", "table", "users", "dangling")
+
+	if ctx := eg.Context(err); ctx["dangling"] != "(MISSING)" || ctx["table"] != "users" {
+		t.Errorf("expected dangling key to get a placeholder, got %v", ctx)
+	}
+}
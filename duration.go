@@ -0,0 +1,28 @@
+package eg
+
+import (
+	"time"
+)
+
+// WithDuration records how long the failed operation took, rendered in Details
+// as took=5s.  If err is not an Err, it is wrapped in one.
+func WithDuration(err error, d time.Duration) error {
+	if err == nil {
+		return nil
+	}
+	e := toErr(err, 1)
+	e.Duration = d
+	return reported(e, err)
+}
+
+// Duration returns the duration of the nearest Err in err's chain that has
+// one.  If no duration is found, ok will be false.
+func Duration(err error) (d time.Duration, ok bool) {
+	walk(err, func(err error) bool {
+		if e, isErr := err.(*Err); isErr && e.Duration != 0 {
+			d, ok = e.Duration, true
+		}
+		return !ok
+	})
+	return d, ok
+}
@@ -0,0 +1,31 @@
+package eg_test
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/natefinch/eg"
+)
+
+func TestWithDuration(t *testing.T) {
+	err := eg.WithDuration(eg.Error("query timed out"), 5*time.Second)
+	err = eg.Note(eg.Note(err, "loading user"), "handling request")
+
+	if d, ok := eg.Duration(err); !ok || d != 5*time.Second {
+		t.Errorf("expected 5s, got %v (ok=%v)", d, ok)
+	}
+	if !strings.Contains(eg.Details(err), "query timed out took=5s") {
+		t.Errorf("expected the duration in Details, got:\n%s", eg.Details(err))
+	}
+	if strings.Contains(err.Error(), "took") {
+		t.Errorf("expected no duration in Error(), got %q", err.Error())
+	}
+}
+
+func TestDurationMissing(t *testing.T) {
+	if _, ok := eg.Duration(eg.Note(errors.New("boom"), "wrapped")); ok {
+		t.Error("expected no duration")
+	}
+}
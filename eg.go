@@ -62,9 +62,14 @@
 package eg
 
 import (
+	"errors"
 	"fmt"
 	"runtime"
+	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
 )
 
 // Annotatable is an interface that represents an error that can aggregate
@@ -90,111 +95,598 @@ type Err struct {
 	Location    location
 	CauseErr    error
 	Annotations []annotation
+	Stack       stack
+	Code        string
+	RequestID   string
+	Context     map[string]interface{}
+	Severity    Severity
+	UserMessage string
+	Args        []string
+	Goroutine   uint64
+	ID          string
+	Duration    time.Duration
+
+	// dropped is the number of annotations discarded to stay within
+	// MaxAnnotations.
+	dropped int
+
+	// stackInDetails renders the error's stack in Details, as set by
+	// MaskWithStack.
+	stackInDetails bool
+
+	// values holds the values set with SetValue, by key, so that keys with
+	// the same name don't collide.
+	values map[*keyID]interface{}
+
+	// frozen marks a shared sentinel, made with Lazy, that must not be
+	// modified.  It is wrapped instead of annotated.
+	frozen bool
 }
 
-var _ error = (*Err)(nil)
+var (
+	_ error       = (*Err)(nil)
+	_ Annotatable = (*Err)(nil)
+	_ Effect      = (*Err)(nil)
+	_ Detailed    = (*Err)(nil)
+)
 
 // Mask returns a new Err object with a message based on the given error's
-// message but without listing the error as the Cause.
+// message but without listing the error as the Cause.  If the error's message
+// is msg, or already starts with msg followed by ": ", it is used as is,
+// rather than repeating msg.  The nearest code in err's chain, if any, is kept,
+// so that clients still get a stable classification.
 func Mask(err error, msg string, args ...interface{}) error {
 	if err == nil {
 		return nil
 	}
-	return mask(err, 1, msg, args...)
+	return created(mask(err, 1, msg, args...))
+}
+
+// MaskWithStack is like Mask, but the stack captured at the mask site is also
+// rendered in Details, beneath the error's own line, so that a masked error
+// that later goes unhandled can still be traced to where it was masked without
+// exposing the original error through Cause or Unwrap.  Whether there is a
+// stack to render follows the StackPolicy, as for Mask; under NoStack, Details
+// shows only the mask site's location.
+func MaskWithStack(err error, msg string, args ...interface{}) error {
+	if err == nil {
+		return nil
+	}
+	ret := mask(err, 1, msg, args...)
+	ret.stackInDetails = true
+	return created(ret)
+}
+
+// MaskType returns a new Err object whose message names only the concrete type
+// of err, such as "internal error (type: *os.PathError)", without exposing
+// err's message or listing it as the Cause.  As with Mask, the nearest code in
+// err's chain, if any, is kept.
+func MaskType(err error) error {
+	if err == nil {
+		return nil
+	}
+	ret := newErr(1, "internal error (type: %T)", err)
+	ret.Code, _ = Code(err)
+	return created(ret)
+}
+
+// Plain returns a plain error with the same message as err, but none of its
+// causes, annotations, or other details, so that callers can't come to depend
+// on them.
+func Plain(err error) error {
+	if err == nil {
+		return nil
+	}
+	return errors.New(err.Error())
 }
 
 func mask(err error, depth int, msg string, args ...interface{}) *Err {
 	ret := newErr(depth+1, msg, args...)
 	if err != nil {
-		if ret.Message != "" {
-			ret.Message = ret.Message + ": " + err.Error()
+		msg := err.Error()
+		if ret.Message != "" && msg != ret.Message && !strings.HasPrefix(msg, ret.Message+": ") {
+			ret.Message = ret.Message + ": " + msg
 		} else {
-			ret.Message = err.Error()
+			ret.Message = msg
 		}
+		ret.Code, _ = Code(err)
 	}
 	return ret
 }
 
 // Error returns a new Err object with the given message.
 func Error(msg string, args ...interface{}) *Err {
-	return newErr(1, msg, args...)
+	return created(newErr(1, msg, args...))
+}
+
+// Lazy returns a new Err object with the given message and no location.  It is
+// intended for package-level sentinel errors, which would otherwise record the
+// package's initialization as their location.
+//
+//	var ErrNotFound = eg.Lazy("not found")
+//
+// Because such errors are shared, they are never modified: Note and the other
+// functions that would annotate or set fields on them wrap them in a new Err
+// instead, so errors.Is still matches the sentinel.  Clone returns a
+// modifiable copy.
+func Lazy(msg string) *Err {
+	return &Err{Message: msg, frozen: true}
+}
+
+// NewNoLoc returns a new Err object with the given message, like Error, but
+// without recording a location or stack.  It is cheaper than Error, and suits
+// generated code or other places where the Go location is meaningless.
+func NewNoLoc(msg string, args ...interface{}) *Err {
+	if len(args) > 0 {
+		msg = sprintf(msg, args...)
+	}
+	return created(&Err{Message: msg})
+}
+
+// OnError, if not nil, is called with every Err created by this package's
+// constructors and by Note and its relatives when they wrap an error, such as
+// for counting or sampling errors.  It is called once the function creating
+// the Err has filled in its fields, such as Code, so that the hook sees the
+// finished error.  It is called on the hot path, so it should be cheap, and it
+// must not retain the Err.  Sentinel errors made with Lazy are not reported.
+var OnError func(*Err)
+
+// created reports e to OnError, if set, and returns it.  Exported functions
+// call it once they have finished filling in a new Err; the unexported
+// constructors don't, so that nothing is reported half-built.
+func created(e *Err) *Err {
+	if OnError != nil {
+		OnError(e)
+	}
+	return e
 }
 
 func newErr(depth int, msg string, args ...interface{}) *Err {
 	if len(args) > 0 {
-		msg = fmt.Sprintf(msg, args...)
+		msg = sprintf(msg, args...)
 	}
 	return &Err{
-		Message:  msg,
-		Location: locate(depth + 1),
+		Message:   msg,
+		Location:  locate(depth + 1),
+		Stack:     capture(depth+1, true),
+		Goroutine: goroutineID(),
+		ID:        newID(),
 	}
-
 }
 
-// Error implements the error interface.
+// Error implements the error interface.  A nil *Err renders as an empty string.
+// Line breaks in messages, including those of foreign causes, are escaped so
+// that the result is always a single line.  An error with nothing to render
+// renders as EmptyMessageFallback.
 func (e *Err) Error() string {
+	if e == nil {
+		return ""
+	}
+	if s := e.render(); s != "" {
+		return s
+	}
+	return EmptyMessageFallback
+}
+
+// render returns the error's Error() string, which is empty if there is
+// nothing to render.
+func (e *Err) render() string {
 	msgs := []string{}
 
-	// LIFO the annotations
-	for x := len(e.Annotations) - 1; x >= 0; x-- {
-		msg := e.Annotations[x].String()
+	for x := 0; x < len(e.Annotations) && AnnotationsInError; x++ {
+		msg := e.Annotations[e.ordered(x)].String()
 		if msg != "" {
-			msgs = append(msgs, e.Annotations[x].String())
+			msgs = append(msgs, oneLine(msg))
 		}
 	}
 
-	msgs = append(msgs, e.Message)
+	if e.Message != "" {
+		msgs = append(msgs, oneLine(truncate(e.Message)))
+	}
 
-	if e.Cause != nil {
-		msgs = append(msgs, e.CauseErr.Error())
+	s := strings.Join(msgs, Separator)
+	if LocationInError && e.Location != (location{}) {
+		s = strings.TrimPrefix(s+" "+e.Location.String(), " ")
+	}
+	if c, ok := e.CauseErr.(*Err); e.CauseErr == nil || ok && c == nil {
+		return s
 	}
-	return strings.Join(msgs, ": ")
+	cause := e.CauseErr.Error()
+	if _, ok := e.CauseErr.(*Err); !ok {
+		cause = oneLine(truncate(cause))
+	}
+	if s == "" {
+		return cause
+	}
+	return s + CauseSeparator + cause
 }
 
 // Cause returns the error object that caused this error.
 func (e *Err) Cause() error {
+	if e == nil {
+		return nil
+	}
 	return e.CauseErr
 }
 
+// SetCause sets the error's cause, for when the cause is only discovered after
+// the error is created.  If the error already has a cause, it is kept and err
+// is ignored; use ReplaceCause to overwrite it.  SetCause returns the error
+// itself.
+func (e *Err) SetCause(err error) *Err {
+	if e != nil && e.CauseErr == nil {
+		e.CauseErr = err
+	}
+	return e
+}
+
+// ReplaceCause sets the error's cause, overwriting any existing cause.  It
+// returns the error itself.
+func (e *Err) ReplaceCause(err error) *Err {
+	if e != nil {
+		e.CauseErr = err
+	}
+	return e
+}
+
+// Unwrap returns the error object that caused this error, for use with
+// errors.Is and errors.As.  It also satisfies xerrors.Wrapper, so
+// xerrors.Unwrap and friends follow eg chains too.
+func (e *Err) Unwrap() error {
+	if e == nil {
+		return nil
+	}
+	return e.CauseErr
+}
+
+// AlwaysWrap, if true, makes Note and its variants always wrap the error in a
+// new Err, rather than annotating errors that are already Annotatable, so
+// that every call adds one layer to the chain.
+var AlwaysWrap = false
+
+// DedupAnnotations, if true, makes Annotate skip annotations that exactly match
+// the error's most recent annotation, such as those added by a function that
+// annotates an error both on its way in and out.
+var DedupAnnotations = false
+
+// MaxAnnotations, if greater than zero, is the most annotations kept on a
+// single Err, such as one that is annotated repeatedly in a loop.  Past the
+// cap, the oldest annotations are dropped and replaced by a single
+// "...(N more)" marker.
+var MaxAnnotations = 0
+
 // Annotate adds the message to the list of annotations on the error.  If msg is
 // empty, the annotation will only be displayed when printing the error's
-// details.
-func (e *Err) Annotate(msg, function, file string, line int) {
-	e.Annotations = append(e.Annotations,
-		annotation{
-			Message:  msg,
-			location: location{function, file, line},
-		})
+// details.  It returns the error itself.  Annotating a nil *Err does nothing
+// and returns nil.  A sentinel made with Lazy is not modified; it is wrapped in
+// a new Err with msg as its message, which is returned instead.
+func (e *Err) Annotate(msg, function, file string, line int) error {
+	if e == nil {
+		return nil
+	}
+	if e.frozen {
+		return created(wrapAt(e, location{function, file, line}, msg))
+	}
+	a := annotation{
+		Message:   msg,
+		location:  location{function, file, line},
+		Goroutine: goroutineID(),
+	}
+	e.addAnnotation(a)
+	return e
+}
+
+// GrowAnnotations makes room for n more annotations without reallocating, for
+// errors known to pass through many layers that annotate them.  It returns the
+// error itself.
+func (e *Err) GrowAnnotations(n int) *Err {
+	if e == nil || n <= 0 {
+		return e
+	}
+	if cap(e.Annotations)-len(e.Annotations) < n {
+		grown := make([]annotation, len(e.Annotations), len(e.Annotations)+n)
+		copy(grown, e.Annotations)
+		e.Annotations = grown
+	}
+	return e
+}
+
+// AnnotateWith annotates the error with msg, as with Annotate at the caller's
+// location, and attaches fields that belong to that annotation alone.  The
+// fields are rendered in Details beneath the annotation's line, sorted by key.
+// Annotating a nil *Err does nothing and returns nil.
+func (e *Err) AnnotateWith(msg string, fields map[string]interface{}) error {
+	if e == nil {
+		return nil
+	}
+	l := locate(1)
+	frozen := e.frozen
+	if frozen {
+		e = wrapAt(e, l, "")
+	}
+	e.addAnnotation(annotation{
+		Message:   msg,
+		location:  l,
+		Goroutine: goroutineID(),
+		Fields:    fields,
+	})
+	if frozen {
+		created(e)
+	}
+	return e
+}
+
+// addAnnotation appends a to the error's annotations, applying
+// DedupAnnotations and MaxAnnotations.
+func (e *Err) addAnnotation(a annotation) {
+	if DedupAnnotations && len(e.Annotations) > 0 && e.Annotations[len(e.Annotations)-1].equal(a) {
+		return
+	}
+	kept := len(e.Annotations)
+	if e.dropped > 0 {
+		kept--
+	}
+	if MaxAnnotations > 0 && kept >= MaxAnnotations {
+		if e.dropped == 0 {
+			// make room for the marker as the oldest annotation
+			e.Annotations = append(e.Annotations, annotation{})
+			copy(e.Annotations[1:], e.Annotations)
+		}
+		n := kept - MaxAnnotations + 1
+		copy(e.Annotations[1:], e.Annotations[1+n:])
+		e.Annotations = e.Annotations[:len(e.Annotations)-n]
+		e.dropped += n
+		e.Annotations[0] = annotation{Message: fmt.Sprintf("...(%d more)", e.dropped)}
+	}
+	e.Annotations = append(e.Annotations, a)
+}
+
+// Clone returns a copy of the error that can be annotated or otherwise
+// modified without affecting the original, such as for customizing a shared
+// template error.  The cause is shared, not copied, and the copy is given its
+// own ID.
+func (e *Err) Clone() *Err {
+	if e == nil {
+		return nil
+	}
+	c := *e
+	c.frozen = false
+	if e.ID != "" {
+		c.ID = newID()
+	}
+	c.Annotations = append([]annotation(nil), e.Annotations...)
+	for x, a := range c.Annotations {
+		if a.Fields != nil {
+			c.Annotations[x].Fields = copyFields(a.Fields)
+		}
+		c.Annotations[x].Args = append([]string(nil), a.Args...)
+	}
+	c.Stack = append(stack(nil), e.Stack...)
+	c.Args = append([]string(nil), e.Args...)
+	if e.Context != nil {
+		c.Context = copyFields(e.Context)
+	}
+	if e.values != nil {
+		c.values = make(map[*keyID]interface{}, len(e.values))
+		for k, v := range e.values {
+			c.values[k] = v
+		}
+	}
+	return &c
+}
+
+// copyFields returns a shallow copy of fields.
+func copyFields(fields map[string]interface{}) map[string]interface{} {
+	c := make(map[string]interface{}, len(fields))
+	for k, v := range fields {
+		c[k] = v
+	}
+	return c
+}
+
+// RangeAnnotations calls fn for each annotation in err's chain, in the order
+// they are rendered: outermost error first, and within each error in
+// AnnotationOrder, newest first by default.  It stops early if fn returns
+// false.  Unlike building a slice of annotations, it does not allocate.
+func RangeAnnotations(err error, fn func(msg, function, file string, line int) bool) {
+	walk(err, func(err error) bool {
+		e, ok := err.(*Err)
+		if !ok {
+			return true
+		}
+		for x := range e.Annotations {
+			a := e.Annotations[e.ordered(x)]
+			if !fn(a.Message, a.Function, a.File, a.Line) {
+				return false
+			}
+		}
+		return true
+	})
+}
+
+// AnnotationAt returns the i'th annotation in err's chain, counting from zero
+// in the order used by RangeAnnotations.  If there is no such annotation, ok
+// is false.
+func AnnotationAt(err error, i int) (msg, function, file string, line int, ok bool) {
+	if i < 0 {
+		return "", "", "", 0, false
+	}
+	n := 0
+	RangeAnnotations(err, func(m, f, fl string, l int) bool {
+		if n == i {
+			msg, function, file, line, ok = m, f, fl, l, true
+			return false
+		}
+		n++
+		return true
+	})
+	return msg, function, file, line, ok
 }
 
 // Details returns a detailed list of annotations including files and line
 // numbers.
 func (e *Err) Details() string {
-	msgs := []string{}
+	if e == nil {
+		return ""
+	}
+	return e.header() + e.details(nil)
+}
 
-	// LIFO the annotations
-	for x := len(e.Annotations) - 1; x >= 0; x-- {
-		msgs = append(msgs, e.Annotations[x].Details())
+// header returns the lines rendered once at the top of the error's details.
+func (e *Err) header() string {
+	if id, ok := RequestID(e); ok {
+		return "request id: " + id + "\n"
 	}
+	return ""
+}
+
+// details renders the error's details in style s.
+func (e *Err) details(s *style) string {
+	w := &lineWriter{}
+	e.writeDetails(w, s)
+	return w.String()
+}
 
-	msgs = append(msgs, fmt.Sprintf("%s %s", e.Location, e.Message))
+// writeDetails writes the error's details, and those of its causes, to w in
+// style s, so that the whole chain is rendered into a single buffer.
+func (e *Err) writeDetails(w *lineWriter, s *style) {
+	for x := range e.Annotations {
+		a := e.Annotations[e.ordered(x)]
+		s.writeLine(w, a.location, withArgs(indent(truncate(a.Message)), a.Args)+crossed(e.Goroutine, a.Goroutine), "")
+		writeFields(w, a.Fields)
+	}
+
+	msg := withArgs(indent(truncate(e.Message)), e.Args)
+	if e.Duration != 0 {
+		msg += " took=" + e.Duration.String()
+	}
+	codeShown := e.Code != "" && s != nil && s.code != ""
+	if e.Location != (location{}) || msg != "" || codeShown || e.CauseErr == nil {
+		// a layer with nothing of its own, such as the one left by
+		// ReplaceRoot, would only render an empty line
+		s.writeLine(w, e.Location, msg, e.Code)
+	}
+	if e.stackInDetails && len(e.Stack) > 0 {
+		for _, frame := range strings.Split(e.Stack.String(), "\n") {
+			w.Line()
+			w.WriteString("\t" + frame)
+		}
+	}
 
 	if e.CauseErr != nil {
-		msgs = append(msgs, Details(e.CauseErr))
+		if c, ok := e.CauseErr.(*Err); ok {
+			if c != nil {
+				c.writeDetails(w, s)
+			}
+		} else {
+			w.Line()
+			w.WriteString(e.foreignDetails())
+		}
 	}
-	return strings.Join(msgs, "\n")
+}
+
+// withArgs returns msg followed by args, as recorded by WithArgs, if there are
+// any.
+func withArgs(msg string, args []string) string {
+	if len(args) == 0 {
+		return msg
+	}
+	if msg != "" {
+		msg += " "
+	}
+	return msg + "args=[" + strings.Join(args, ", ") + "]"
+}
+
+// foreignRendering is the number of foreign causes being rendered by Details
+// on any goroutine.  While it is zero, rendering one can't be recursion, so the
+// slower per-goroutine bookkeeping in rendering is skipped.
+var foreignRendering int32
+
+// rendering holds the Errs whose foreign causes are being rendered, per
+// goroutine, so that a cause whose Error calls Details on an error wrapping it
+// can't recurse forever.
+var rendering = struct {
+	sync.Mutex
+	errs map[renderKey]bool
+}{errs: map[renderKey]bool{}}
+
+type renderKey struct {
+	goroutine uint64
+	err       *Err
+}
+
+// foreignDetails returns the details of the error's cause, which is not an Err.
+// The cause's methods must not call Details on an error wrapping the cause; if
+// they do, the repeated rendering is replaced by a marker.
+func (e *Err) foreignDetails() string {
+	defer atomic.AddInt32(&foreignRendering, -1)
+	if atomic.AddInt32(&foreignRendering, 1) == 1 {
+		// Nothing else is being rendered, so this can't be a repeat.  If the
+		// cause does recurse, the nested calls take the slow path below and
+		// stop at the first repeat there.
+		return Details(e.CauseErr)
+	}
+
+	key := renderKey{currentGoroutine(), e}
+	rendering.Lock()
+	if rendering.errs[key] {
+		rendering.Unlock()
+		return "(cycle detected)"
+	}
+	rendering.errs[key] = true
+	rendering.Unlock()
+
+	defer func() {
+		rendering.Lock()
+		delete(rendering.errs, key)
+		rendering.Unlock()
+	}()
+	return Details(e.CauseErr)
+}
+
+// StackTrace returns the stack captured when the error was created, one frame
+// per line.  Whether the error has a stack depends on the StackPolicy in effect
+// when it was created.
+func (e *Err) StackTrace() string {
+	if e == nil {
+		return ""
+	}
+	return e.Stack.String()
 }
 
 func wrap(err error, depth int, msg string, args ...interface{}) *Err {
+	e := wrapAt(err, locate(depth+1), msg, args...)
+	e.Stack = capture(depth+1, false)
+	return e
+}
+
+func wrapAt(err error, l location, msg string, args ...interface{}) *Err {
 	if len(args) > 0 {
-		msg = fmt.Sprintf(msg, args...)
+		msg = sprintf(msg, args...)
 	}
 
-	return &Err{Message: msg, CauseErr: err, Location: locate(depth + 1)}
+	return &Err{Message: msg, CauseErr: err, Location: l, Goroutine: goroutineID()}
 }
 
 // Note annotates the error if it is already an Annotable error, otherwise it
-// wraps the error in an Err using msg as the error's message.
+// wraps the error in an Err using msg as the error's message.  If AlwaysWrap
+// is true, the error is always wrapped.  If msg is empty and the error has a
+// severity, the severity's label, such as "[WARN]", is used as the message.
+//
+// An Err is Annotatable, so noting one adds the annotation to that Err in place
+// and returns it, rather than adding a layer, and anything else holding the Err
+// sees the annotation too.  Clone an Err that is shared before noting it, or
+// make it with Lazy, which is wrapped rather than annotated.
+//
+// Noting with an empty message is a valid way to record only a location: the
+// empty message is left out of Error, which renders exactly as the noted
+// error does, with no stray separators.  The exception is an error with a
+// severity, whose label takes the place of the empty message as described
+// above, so that it renders as "[WARN]: cause" instead.
+//
+// The location recorded is that of the caller of Note.  When Note is called
+// from a deferred closure, that is the closure itself; use NoteDeferred to
+// record the function that deferred the closure instead.
 func Note(err error, msg string, args ...interface{}) error {
 	if err == nil {
 		return nil
@@ -202,32 +694,249 @@ func Note(err error, msg string, args ...interface{}) error {
 	return note(err, 1, msg, args...)
 }
 
+// NoteOr is like Note, but when err is nil it returns a new Err created from
+// msg, as with Error, rather than nil.  Use it where an error must be produced
+// either way; Note is the right choice for passing along an error that may be
+// nil.
+func NoteOr(err error, msg string, args ...interface{}) error {
+	if err == nil {
+		return created(newErr(1, msg, args...))
+	}
+	return note(err, 1, msg, args...)
+}
+
+// NoteFunc notes err, as with Note, using the short name of the calling
+// function as the message, such as "Bootstrap" or "(*Server).Start", so that
+// callers needn't spell out where they are.
+func NoteFunc(err error) error {
+	if err == nil {
+		return nil
+	}
+	l := locate(1)
+	return noteAt(err, 1, l, shortFuncName(l.Function))
+}
+
+// shortFuncName returns function without its package path.
+func shortFuncName(function string) string {
+	function = function[strings.LastIndexByte(function, '/')+1:]
+	if i := strings.IndexByte(function, '.'); i >= 0 {
+		return function[i+1:]
+	}
+	return function
+}
+
+// NoteDeferred is like Note, but is intended to be called from a deferred
+// closure, such as
+//
+//	defer func() { err = eg.NoteDeferred(err, "can't bootstrap") }()
+//
+// It records the location of the function that deferred the closure, rather
+// than the closure itself.
+func NoteDeferred(err error, msg string, args ...interface{}) error {
+	if err == nil {
+		return nil
+	}
+	return noteAt(err, 1, locateDeferred(1), msg, args...)
+}
+
+// NoteAt is like Note, but records the given location rather than the caller's.
+// It lets code generators and interpreters report positions in the source a
+// user wrote, such as a template or script line.
+func NoteAt(err error, function, file string, line int, msg string, args ...interface{}) error {
+	if err == nil {
+		return nil
+	}
+	return noteAt(err, 1, location{function, file, line}, msg, args...)
+}
+
+// NoteAll notes err with each of msgs in turn, as with Note, all recording the
+// caller's location, such as for recording several pieces of context at one
+// boundary.  The last message is the newest annotation.  The messages are not
+// format strings.
+func NoteAll(err error, msgs ...string) error {
+	if err == nil {
+		return nil
+	}
+	l := locate(1)
+	for _, msg := range msgs {
+		err = noteAt(err, 1, l, msg)
+	}
+	return err
+}
+
 func note(err error, depth int, msg string, args ...interface{}) error {
-	if a, ok := err.(Annotatable); ok {
+	return noteAt(err, depth+1, locate(depth+1), msg, args...)
+}
 
-		l := locate(depth + 1)
+// noteAt notes err with msg at l.  If err is wrapped, the new Err's stack, if
+// the StackPolicy calls for one, is that of the caller depth levels above the
+// caller of noteAt.
+func noteAt(err error, depth int, l location, msg string, args ...interface{}) error {
+	noted, wrapped := annotateAt(err, depth+1, l, msg, args...)
+	if wrapped != nil {
+		created(wrapped)
+	}
+	return noted
+}
+
+// annotateAt is noteAt without reporting to OnError.  If err was wrapped, the
+// new Err is also returned as wrapped, for the caller to report once it has
+// filled in its fields.
+func annotateAt(err error, depth int, l location, msg string, args ...interface{}) (noted error, wrapped *Err) {
+	msg = defaultMessage(err, msg)
+	a, ok := err.(Annotatable)
+	if e, isErr := err.(*Err); isErr && e != nil && e.frozen {
+		// wrap sentinels here, rather than in Annotate, which reports them
+		ok = false
+	}
+	if ok && !AlwaysWrap {
 		if len(args) == 0 {
-			return a.Annotate(msg, l.Function, l.File, l.Line)
+			return a.Annotate(msg, l.Function, l.File, l.Line), nil
 		} else {
-			return a.Annotate(fmt.Sprintf(msg, args), l.Function, l.File, l.Line)
+			return a.Annotate(sprintf(msg, args...), l.Function, l.File, l.Line), nil
 		}
 	}
 
-	return wrap(err, depth+1, msg, args...)
+	e := wrapAt(err, l, msg, args...)
+	e.Stack = capture(depth+1, false)
+	return e, e
+}
+
+// toErr returns err if it is an Err that may be modified, otherwise it wraps
+// err in a new Err, recording the location depth levels above the caller of
+// toErr.  A nil *Err is wrapped too, and renders as the wrapper alone.
+func toErr(err error, depth int) *Err {
+	if e, ok := err.(*Err); ok && e != nil && !e.frozen {
+		return e
+	}
+	return wrap(err, depth+1, "")
+}
+
+// reported reports e to OnError if toErr made it to wrap err, now that the
+// caller has filled in its fields, and returns it.
+func reported(e *Err, err error) *Err {
+	if error(e) != err {
+		created(e)
+	}
+	return e
+}
+
+// WithArgs records a snapshot of the arguments of the function that failed,
+// rendered in Details as args=[...] on the line of the caller's location.  Each
+// argument is formatted with %v immediately.  If err is an Err, the snapshot is
+// added to it as an annotation with no message, so that each call is shown
+// against its own caller; otherwise err is wrapped in an Err holding the args.
+func WithArgs(err error, args ...interface{}) error {
+	if err == nil {
+		return nil
+	}
+	formatted := make([]string, len(args))
+	for x, arg := range args {
+		formatted[x] = fmt.Sprintf("%v", arg)
+	}
+	e, ok := err.(*Err)
+	if !ok || e == nil || e.frozen {
+		e = wrap(err, 1, "")
+		e.Args = formatted
+		return created(e)
+	}
+	e.addAnnotation(annotation{
+		location:  locate(1),
+		Goroutine: goroutineID(),
+		Args:      formatted,
+	})
+	return e
+}
+
+// Enrich wraps err in an Err that records the caller's location and stack if
+// err is not already Detailed, so that errors received from other packages
+// start being tracked.  Detailed errors are returned unchanged.
+func Enrich(err error) error {
+	if err == nil {
+		return nil
+	}
+	if _, ok := err.(Detailed); ok {
+		return err
+	}
+	e := wrap(err, 1, "")
+	e.Stack = capture(1, true)
+	e.ID = newID()
+	return created(e)
+}
+
+// Check panics with an Err wrapping err if err is non-nil, and does nothing
+// otherwise.  It is intended for the top of main or simple scripts where
+// returning the error is not desired.  The panic value is an *Err, so a
+// recover can log its Details.
+func Check(err error) {
+	if err != nil {
+		panic(created(wrap(err, 1, "")))
+	}
 }
 
 // Cause returns the cause of the error.  If the error has a cause, ok will be
 // true, and cause will contain the cause.  Otherwise the err will be returned
-// as the cause.
+// as the cause.  Causes are found using the registered cause resolvers; see
+// RegisterCauseResolver.
 func Cause(err error) (cause error, ok bool) {
 	if err == nil {
 		return nil, false
 	}
-	e, ok := err.(Effect)
-	if !ok {
-		return err, false
+	for _, resolve := range resolvers {
+		if cause, ok := resolve(err); ok {
+			return cause, true
+		}
+	}
+	return err, false
+}
+
+// CauseErr returns err's immediate cause, as found by Cause, if it is an Err.
+// Otherwise ok will be false.  It lets tools descend through only the Err
+// layers of a chain.
+func CauseErr(err error) (e *Err, ok bool) {
+	cause, found := Cause(err)
+	if !found {
+		return nil, false
+	}
+	e, ok = cause.(*Err)
+	return e, ok
+}
+
+// AsErr returns the first Err in err's chain, as found by errors.As.  If there
+// is none, ok will be false.
+func AsErr(err error) (e *Err, ok bool) {
+	ok = errors.As(err, &e)
+	return e, ok
+}
+
+// IsEg reports whether err, or any error in its chain, is an Err.  It is
+// cheaper than AsErr when the Err itself isn't needed.
+func IsEg(err error) bool {
+	found := false
+	walk(err, func(err error) bool {
+		_, found = err.(*Err)
+		return !found
+	})
+	return found
+}
+
+// walk calls fn for err and each of its causes in turn, outermost first,
+// stopping when fn returns false or the chain ends.
+func walk(err error, fn func(error) bool) {
+	for err != nil {
+		if e, ok := err.(*Err); ok && e == nil {
+			// a typed nil ends the chain
+			return
+		}
+		if !fn(err) {
+			return
+		}
+		cause, ok := Cause(err)
+		if !ok {
+			return
+		}
+		err = cause
 	}
-	return e.Cause(), true
 }
 
 // Details returns detailed information about the error, or the error's Error()
@@ -239,7 +948,32 @@ func Details(err error) string {
 	if e, ok := err.(Detailed); ok {
 		return e.Details()
 	}
-	return err.Error()
+	if j, ok := err.(joined); ok {
+		return joinedDetails(j.Unwrap())
+	}
+	return indent(truncate(err.Error()))
+}
+
+// joined is implemented by errors that combine several errors, such as the
+// result of errors.Join.
+type joined interface {
+	Unwrap() []error
+}
+
+// joinedDetails renders the details of each error as its own indented block.
+func joinedDetails(errs []error) string {
+	msgs := []string{}
+	for _, err := range errs {
+		if err == nil {
+			continue
+		}
+		lines := strings.Split(Details(err), "\n")
+		for x := range lines {
+			lines[x] = "\t" + lines[x]
+		}
+		msgs = append(msgs, strings.Join(lines, "\n"))
+	}
+	return strings.Join(msgs, "\n")
 }
 
 // location is a line in source control
@@ -250,27 +984,155 @@ type location struct {
 }
 
 func (l location) String() string {
-	return fmt.Sprintf("[%s@%s:%d]", l.Function, l.File, l.Line)
+	b := &strings.Builder{}
+	l.writeTo(b)
+	return b.String()
+}
+
+// writeTo writes the location to b, as rendered by String.  Binaries built
+// without symbols or with generated code may lack a function or file; rather
+// than rendering a misleading "[@:0]", what is known is rendered, or
+// "[optimized]" if nothing is.
+func (l location) writeTo(b *strings.Builder) {
+	noFile := l.File == "" || l.File == "<autogenerated>"
+	if noFile && l.Function == "" {
+		b.WriteString("[optimized]")
+		return
+	}
+	b.WriteByte('[')
+	if l.Function == "" {
+		b.WriteByte('?')
+	} else {
+		b.WriteString(l.Function)
+	}
+	if noFile {
+		b.WriteByte(']')
+		return
+	}
+	b.WriteByte('@')
+	b.WriteString(strings.TrimPrefix(l.File, trimPrefix))
+	b.WriteByte(':')
+	var buf [20]byte
+	b.Write(strconv.AppendInt(buf[:0], int64(l.Line), 10))
+	b.WriteByte(']')
+}
+
+// trimPrefix is removed from file paths when rendering locations.
+var trimPrefix string
+
+// SetTrimPrefix sets a prefix, such as a module root or GOPATH, that is
+// removed from file paths when locations are rendered.  The recorded paths are
+// unchanged.  It should be called during initialization, before errors are
+// rendered.
+func SetTrimPrefix(prefix string) {
+	trimPrefix = prefix
+}
+
+// locationOverride, if set, is recorded in place of every captured location.
+var locationOverride *location
+
+// SetLocationOverride makes every location recorded from now on the given
+// one, so that golden tests of Error and Details don't change whenever the
+// code they exercise moves.  Stacks are unaffected.  It returns a function
+// that removes the override.  It is intended only for tests, and must not be
+// called while errors are being created concurrently.
+func SetLocationOverride(function, file string, line int) (restore func()) {
+	prev := locationOverride
+	locationOverride = &location{function, file, line}
+	return func() { locationOverride = prev }
 }
 
 // locate returns info about thje line of sourcecode depth levels above the
 // caller of locate.
 func locate(depth int) location {
+	if locationOverride != nil {
+		return *locationOverride
+	}
 	pc, file, line, _ := runtime.Caller(depth + 1)
 	function := runtime.FuncForPC(pc).Name()
 	return location{function, file, line}
 }
 
+// locateDeferred returns info about the function that deferred the closure
+// depth levels above the caller of locateDeferred.  That function is found by
+// the closure's name, such as "pkg.F" for "pkg.F.func1", so that frames between
+// them, such as those of a panic in progress and the callee that raised it,
+// are skipped.  If the caller isn't a closure, the first frame above it that
+// isn't in the runtime is used.
+func locateDeferred(depth int) location {
+	if locationOverride != nil {
+		return *locationOverride
+	}
+	pcs := make([]uintptr, maxStackDepth)
+	n := runtime.Callers(depth+2, pcs)
+	frames := runtime.CallersFrames(pcs[:n])
+	closure, more := frames.Next()
+	parent, isClosure := closureParent(closure.Function)
+	found := location{closure.Function, closure.File, closure.Line}
+	outside := false
+	for more {
+		var f runtime.Frame
+		f, more = frames.Next()
+		if isClosure && f.Function == parent {
+			return location{f.Function, f.File, f.Line}
+		}
+		if !outside && !strings.HasPrefix(f.Function, "runtime.") {
+			found, outside = location{f.Function, f.File, f.Line}, true
+			if !isClosure {
+				return found
+			}
+		}
+	}
+	return found
+}
+
+// closureParent returns the name of the function that encloses the closure
+// named function, such as "pkg.F" for "pkg.F.func1" and "pkg.F.func1" for
+// "pkg.F.func1.2".  If function isn't a closure, ok is false.
+func closureParent(function string) (parent string, ok bool) {
+	i := strings.LastIndexByte(function, '.')
+	if i < 0 {
+		return "", false
+	}
+	suffix := strings.TrimPrefix(function[i+1:], "func")
+	if suffix == "" || strings.Trim(suffix, "0123456789") != "" {
+		return "", false
+	}
+	return function[:i], true
+}
+
 // annotation is a message associated with a location.
 type annotation struct {
 	Message string
 	location
+	Goroutine uint64
+	Event     string
+	Fields    map[string]interface{}
+	Args      []string
+}
+
+// ordered returns the index in the error's annotations of the x'th annotation
+// in AnnotationOrder.
+func (e *Err) ordered(x int) int {
+	if AnnotationOrder == OldestFirst {
+		return x
+	}
+	return len(e.Annotations) - 1 - x
+}
+
+// equal reports whether a and b are the same annotation.  Annotations with
+// fields or args are never considered equal.
+func (a annotation) equal(b annotation) bool {
+	return len(a.Fields) == 0 && len(b.Fields) == 0 &&
+		len(a.Args) == 0 && len(b.Args) == 0 &&
+		a.Message == b.Message && a.location == b.location &&
+		a.Goroutine == b.Goroutine && a.Event == b.Event
 }
 
 func (a annotation) String() string {
-	return a.Message
+	return truncate(a.Message)
 }
 
 func (a annotation) Details() string {
-	return fmt.Sprintf("%s %s", a.location, a.Message)
+	return fmt.Sprintf("%s %s", a.location, truncate(a.Message))
 }
@@ -3,6 +3,14 @@ package eg_test
 import (
 	"errors"
 	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+	"testing"
+	"time"
 
 	"github.com/natefinch/eg"
 )
@@ -17,3 +25,981 @@ func ExampleNote() {
 	// Output:
 	// second annotation: first annotation: Original error string
 }
+
+func TestDetailsJoinedCause(t *testing.T) {
+	err := eg.Note(errors.Join(eg.Error("a"), eg.Error("b")), "joined")
+
+	lines := strings.Split(eg.Details(err), "\n")
+	if len(lines) != 3 {
+		t.Fatalf("expected 3 lines of details, got %d:\n%s", len(lines), eg.Details(err))
+	}
+	if !strings.HasSuffix(lines[0], " joined") {
+		t.Errorf("expected first line to be the outer error, got %q", lines[0])
+	}
+	for x, msg := range []string{"a", "b"} {
+		line := lines[x+1]
+		if !strings.HasPrefix(line, "\t[") || !strings.HasSuffix(line, "] "+msg) {
+			t.Errorf("expected indented details for %q, got %q", msg, line)
+		}
+	}
+}
+
+func TestCheckNil(t *testing.T) {
+	defer func() {
+		if r := recover(); r != nil {
+			t.Fatalf("expected no panic, got %v", r)
+		}
+	}()
+	eg.Check(nil)
+}
+
+func TestCheckPanics(t *testing.T) {
+	orig := errors.New("boom")
+	defer func() {
+		e, ok := recover().(*eg.Err)
+		if !ok {
+			t.Fatal("expected panic with an *eg.Err")
+		}
+		if e.Cause() != orig {
+			t.Errorf("expected cause %v, got %v", orig, e.Cause())
+		}
+	}()
+	eg.Check(orig)
+}
+
+func TestSetTrimPrefix(t *testing.T) {
+	_, file, _, _ := runtime.Caller(0)
+	dir := filepath.Dir(file) + "/"
+
+	eg.SetTrimPrefix(dir)
+	defer eg.SetTrimPrefix("")
+
+	details := eg.Details(eg.Error("trimmed"))
+	if strings.Contains(details, dir) {
+		t.Errorf("expected %q to be trimmed from details, got %q", dir, details)
+	}
+	if !strings.Contains(details, "@eg_test.go:") {
+		t.Errorf("expected module-relative path in details, got %q", details)
+	}
+}
+
+func TestRangeAnnotations(t *testing.T) {
+	inner := eg.Note(eg.Error("root"), "first")
+	inner = eg.Note(inner, "second")
+	var err error = &eg.Err{Message: "wrapped", CauseErr: inner}
+	err = eg.Note(err, "outer")
+
+	expected := []string{}
+	for _, e := range []*eg.Err{err.(*eg.Err), inner.(*eg.Err)} {
+		for x := len(e.Annotations) - 1; x >= 0; x-- {
+			expected = append(expected, e.Annotations[x].Message)
+		}
+	}
+
+	got := []string{}
+	eg.RangeAnnotations(err, func(msg, function, file string, line int) bool {
+		got = append(got, msg)
+		return true
+	})
+	if strings.Join(got, ",") != strings.Join(expected, ",") {
+		t.Errorf("expected annotations %q, got %q", expected, got)
+	}
+}
+
+func TestRangeAnnotationsStops(t *testing.T) {
+	err := eg.Note(eg.Note(eg.Error("root"), "first"), "second")
+
+	count := 0
+	eg.RangeAnnotations(err, func(msg, function, file string, line int) bool {
+		count++
+		return false
+	})
+	if count != 1 {
+		t.Errorf("expected iteration to stop after 1 annotation, got %d", count)
+	}
+}
+
+func TestAnnotationAt(t *testing.T) {
+	inner := eg.Note(eg.Note(eg.Error("root"), "first"), "second")
+	err := eg.Note(&eg.Err{Message: "wrapped", CauseErr: inner}, "outer")
+
+	for i, want := range []string{"outer", "second", "first"} {
+		msg, function, _, line, ok := eg.AnnotationAt(err, i)
+		if !ok || msg != want {
+			t.Errorf("expected annotation %d to be %q, got %q (ok=%v)", i, want, msg, ok)
+		}
+		if !strings.HasSuffix(function, "TestAnnotationAt") || line == 0 {
+			t.Errorf("expected annotation %d's location, got %s:%d", i, function, line)
+		}
+	}
+	for _, i := range []int{-1, 3, 100} {
+		if _, _, _, _, ok := eg.AnnotationAt(err, i); ok {
+			t.Errorf("expected no annotation at %d", i)
+		}
+	}
+}
+
+func TestGrowAnnotations(t *testing.T) {
+	grown := eg.Note(eg.Error("root"), "first").(*eg.Err).GrowAnnotations(16)
+	plain := eg.Note(eg.Error("root"), "first").(*eg.Err)
+	if cap(grown.Annotations) < 17 {
+		t.Errorf("expected room for 16 more annotations, got capacity %d", cap(grown.Annotations))
+	}
+	for _, e := range []*eg.Err{grown, plain} {
+		eg.Note(e, "second")
+	}
+	if grown.Error() != plain.Error() || len(grown.Annotations) != len(plain.Annotations) {
+		t.Errorf("expected growing to change nothing else, got %q and %q", grown.Error(), plain.Error())
+	}
+}
+
+func benchmarkAnnotate(b *testing.B, grow int) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		e := eg.NewNoLoc("root").GrowAnnotations(grow)
+		for x := 0; x < 16; x++ {
+			e.Annotate("layer", "fn", "file.go", x)
+		}
+	}
+}
+
+func BenchmarkAnnotate(b *testing.B)      { benchmarkAnnotate(b, 0) }
+func BenchmarkAnnotateGrown(b *testing.B) { benchmarkAnnotate(b, 16) }
+
+func BenchmarkRangeAnnotations(b *testing.B) {
+	err := eg.Note(eg.Note(eg.Error("root"), "first"), "second")
+	fn := func(msg, function, file string, line int) bool { return true }
+
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		eg.RangeAnnotations(err, fn)
+	}
+}
+
+func noteInDefer() (err error) {
+	defer func() { err = eg.Note(err, "deferred") }()
+	return eg.Error("boom")
+}
+
+func noteDeferred() (err error) {
+	defer func() { err = eg.NoteDeferred(err, "deferred") }()
+	return eg.Error("boom")
+}
+
+func noteDeferredPanic() (err error) {
+	defer func() {
+		recover()
+		err = eg.NoteDeferred(eg.Error("boom"), "deferred")
+	}()
+	panic("boom")
+}
+
+func noteDeferredCalleePanic() (err error) {
+	defer func() {
+		recover()
+		err = eg.NoteDeferred(eg.Error("boom"), "deferred")
+	}()
+	panicking()
+	return nil
+}
+
+//go:noinline
+func panicking() {
+	panic("boom")
+}
+
+func TestNoteInDeferRecordsClosure(t *testing.T) {
+	eg.RangeAnnotations(noteInDefer(), func(msg, function, file string, line int) bool {
+		if !strings.HasSuffix(function, ".noteInDefer.func1") {
+			t.Errorf("expected the deferred closure to be recorded, got %q", function)
+		}
+		return true
+	})
+}
+
+func TestNoteDeferred(t *testing.T) {
+	for _, err := range []error{noteDeferred(), noteDeferredPanic(), noteDeferredCalleePanic()} {
+		count := 0
+		eg.RangeAnnotations(err, func(msg, function, file string, line int) bool {
+			count++
+			switch function {
+			case "github.com/natefinch/eg_test.noteDeferred",
+				"github.com/natefinch/eg_test.noteDeferredPanic",
+				"github.com/natefinch/eg_test.noteDeferredCalleePanic":
+			default:
+				t.Errorf("expected the deferring function to be recorded, got %q", function)
+			}
+			return true
+		})
+		if count != 1 {
+			t.Errorf("expected 1 annotation, got %d", count)
+		}
+	}
+}
+
+func TestEnrich(t *testing.T) {
+	orig := errors.New("boom")
+	err := eg.Enrich(orig)
+
+	details := eg.Details(err)
+	if !strings.Contains(details, "eg_test.TestEnrich@") {
+		t.Errorf("expected details to include the enriching location, got %q", details)
+	}
+	if !errors.Is(err, orig) {
+		t.Error("expected enriched error to wrap the original")
+	}
+	if !strings.Contains(err.(*eg.Err).StackTrace(), "eg_test.TestEnrich@") {
+		t.Error("expected enriched error to capture a stack")
+	}
+	if eg.Enrich(err) != err {
+		t.Error("expected detailed errors to be returned unchanged")
+	}
+}
+
+var errLazy = eg.Lazy("lazy sentinel")
+
+func TestLazy(t *testing.T) {
+	if errLazy.Location.Function != "" || errLazy.Location.File != "" || errLazy.Location.Line != 0 {
+		t.Errorf("expected no location, got %v", errLazy.Location)
+	}
+	if len(errLazy.Stack) != 0 {
+		t.Errorf("expected no stack, got %v", errLazy.StackTrace())
+	}
+	if errLazy.Error() != "lazy sentinel" {
+		t.Errorf("expected %q, got %q", "lazy sentinel", errLazy.Error())
+	}
+}
+
+func TestLazyIsNotModified(t *testing.T) {
+	sentinel := eg.Lazy("not found")
+
+	first := eg.Note(sentinel, "finding user")
+	second := eg.Note(sentinel, "finding user")
+	eg.WithRequestID(sentinel, "req-1")
+	eg.WithSeverity(sentinel, eg.SeverityWarn)
+	sentinel.AnnotateWith("finding order", map[string]interface{}{"id": 7})
+
+	var wg sync.WaitGroup
+	for x := 0; x < 2; x++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			eg.Note(sentinel, "concurrently")
+		}()
+	}
+	wg.Wait()
+
+	if sentinel.Error() != "not found" || len(sentinel.Annotations) != 0 || sentinel.RequestID != "" || sentinel.Severity != 0 {
+		t.Errorf("expected the sentinel to be unchanged, got %#v", sentinel)
+	}
+	for _, err := range []error{first, second} {
+		if err.Error() != "finding user: not found" {
+			t.Errorf("expected the sentinel to be wrapped, got %q", err.Error())
+		}
+		if !errors.Is(err, sentinel) {
+			t.Error("expected errors.Is to match the sentinel")
+		}
+	}
+	if c := sentinel.Clone(); eg.Note(c, "customized") != c {
+		t.Error("expected a clone of the sentinel to be annotated in place")
+	}
+}
+
+func TestDedupAnnotations(t *testing.T) {
+	eg.DedupAnnotations = true
+	defer func() { eg.DedupAnnotations = false }()
+
+	err := eg.Error("root")
+	for x := 0; x < 2; x++ {
+		eg.Note(err, "same")
+	}
+	eg.Note(err, "different")
+
+	if len(err.Annotations) != 2 {
+		t.Errorf("expected 2 annotations, got %d", len(err.Annotations))
+	}
+}
+
+func TestMaxAnnotations(t *testing.T) {
+	eg.MaxAnnotations = 10
+	defer func() { eg.MaxAnnotations = 0 }()
+
+	err := eg.Error("root")
+	for x := 0; x < 100; x++ {
+		eg.Note(err, "attempt %d", x)
+	}
+
+	if len(err.Annotations) != 11 {
+		t.Errorf("expected 10 annotations plus a marker, got %d", len(err.Annotations))
+	}
+	want := "attempt 99: attempt 98: attempt 97: attempt 96: attempt 95: attempt 94: " +
+		"attempt 93: attempt 92: attempt 91: attempt 90: ...(90 more): root"
+	if err.Error() != want {
+		t.Errorf("expected %q, got %q", want, err.Error())
+	}
+}
+
+func TestDedupAnnotationsOff(t *testing.T) {
+	err := eg.Error("root")
+	for x := 0; x < 2; x++ {
+		eg.Note(err, "same")
+	}
+
+	if len(err.Annotations) != 2 {
+		t.Errorf("expected 2 annotations, got %d", len(err.Annotations))
+	}
+}
+
+func TestCauseErr(t *testing.T) {
+	inner := eg.Error("inner")
+	if e, ok := eg.CauseErr(eg.Mask(inner, "outer")); ok {
+		t.Errorf("expected a masked error to have no cause, got %v", e)
+	}
+	if e, ok := eg.CauseErr(eg.Note(inner, "annotated")); ok {
+		t.Errorf("expected an annotated root to have no cause, got %v", e)
+	}
+	if e, ok := eg.CauseErr(eg.Error("outer").SetCause(inner)); !ok || e != inner {
+		t.Errorf("expected the Err cause, got %v (ok=%v)", e, ok)
+	}
+	if e, ok := eg.CauseErr(eg.Note(errors.New("plain"), "wrapped")); ok {
+		t.Errorf("expected a plain cause not to be returned, got %v", e)
+	}
+}
+
+func TestAsErr(t *testing.T) {
+	orig := eg.Error("root")
+	err := fmt.Errorf("plain wrapper: %w", orig)
+
+	e, ok := eg.AsErr(err)
+	if !ok || e != orig {
+		t.Errorf("expected to find %v, got %v (ok=%v)", orig, e, ok)
+	}
+}
+
+func TestAsErrNone(t *testing.T) {
+	e, ok := eg.AsErr(fmt.Errorf("plain wrapper: %w", errors.New("root")))
+	if ok || e != nil {
+		t.Errorf("expected no Err, got %v (ok=%v)", e, ok)
+	}
+}
+
+func TestClone(t *testing.T) {
+	template := eg.Note(eg.SetValue(eg.Error("template"), attemptsKey, 1), "base").(*eg.Err)
+
+	clone := template.Clone()
+	eg.Note(clone, "customized")
+	eg.SetValue(clone, attemptsKey, 2)
+
+	if len(template.Annotations) != 1 {
+		t.Errorf("expected template to keep 1 annotation, got %d", len(template.Annotations))
+	}
+	if n, _ := eg.Value(template, attemptsKey); n != 1 {
+		t.Errorf("expected template to keep its context value, got %d", n)
+	}
+	if clone.Error() != "customized: base: template" {
+		t.Errorf("expected clone to be annotated, got %q", clone.Error())
+	}
+}
+
+func TestCloneArgsAndFields(t *testing.T) {
+	template := eg.WithArgs(eg.Error("template"), 1, 2, 3).(*eg.Err)
+	template.AnnotateWith("querying", map[string]interface{}{"table": "users"})
+
+	clone := template.Clone()
+	eg.WithArgs(clone, "clone")
+	eg.WithArgs(template, "template")
+	clone.Annotations[1].Fields["table"] = "orders"
+
+	if d := clone.Details(); !strings.Contains(d, "args=[clone]") || strings.Contains(d, "args=[template]") {
+		t.Errorf("expected the clone's own args, got:\n%s", d)
+	}
+	if d := template.Details(); !strings.Contains(d, "args=[template]") || strings.Contains(d, "args=[clone]") {
+		t.Errorf("expected the template's own args, got:\n%s", d)
+	}
+	if got := template.Annotations[1].Fields["table"]; got != "users" {
+		t.Errorf("expected the template's fields to be unchanged, got %v", got)
+	}
+}
+
+func TestPlain(t *testing.T) {
+	err := eg.Note(eg.Error("root"), "annotated")
+	plain := eg.Plain(err)
+
+	var e *eg.Err
+	if errors.As(plain, &e) {
+		t.Error("expected plain error not to be an *eg.Err")
+	}
+	if _, ok := plain.(eg.Detailed); ok {
+		t.Error("expected plain error not to be Detailed")
+	}
+	if plain.Error() != err.Error() {
+		t.Errorf("expected %q, got %q", err.Error(), plain.Error())
+	}
+}
+
+func TestNoteAnnotatesInPlace(t *testing.T) {
+	root := eg.Error("disk full")
+	err := eg.Note(root, "saving")
+
+	if e, ok := err.(*eg.Err); !ok || e != root {
+		t.Fatalf("expected Note to return the same Err, got %#v", err)
+	}
+	if len(root.Annotations) != 1 || root.Annotations[0].Message != "saving" {
+		t.Errorf("expected the note to be added to the Err, got %v", root.Annotations)
+	}
+	if root.Error() != "saving: disk full" {
+		t.Errorf("expected %q, got %q", "saving: disk full", root.Error())
+	}
+	if depth(err) != 1 {
+		t.Errorf("expected no new layer, got depth %d", depth(err))
+	}
+
+	plain := errors.New("disk full")
+	err = eg.Note(plain, "saving")
+	if e, ok := err.(*eg.Err); !ok || e.CauseErr != plain || e.Message != "saving" {
+		t.Errorf("expected a plain error to be wrapped, got %#v", err)
+	}
+}
+
+func TestNoteEmptyMessage(t *testing.T) {
+	eg.AlwaysWrap = true
+	defer func() { eg.AlwaysWrap = false }()
+
+	for _, cause := range []error{
+		errors.New("disk full"),
+		eg.Note(eg.Error("disk full"), "saving"),
+		fmt.Errorf("saving: %w", errors.New("disk full")),
+	} {
+		err := eg.Note(eg.Note(cause, ""), "")
+		if err.Error() != cause.Error() {
+			t.Errorf("expected exactly %q, got %q", cause.Error(), err.Error())
+		}
+		if depth(err) < 3 {
+			t.Errorf("expected the empty notes to wrap, got depth %d", depth(err))
+		}
+	}
+}
+
+func TestNoteEmptyMessageSeverity(t *testing.T) {
+	eg.AlwaysWrap = true
+	defer func() { eg.AlwaysWrap = false }()
+
+	cause := eg.WithSeverity(errors.New("disk almost full"), eg.SeverityWarn)
+	if err := eg.Note(cause, ""); err.Error() != "[WARN]: disk almost full" {
+		t.Errorf("expected the severity label in place of the empty message, got %q", err.Error())
+	}
+}
+
+func TestErrorEmptyMessage(t *testing.T) {
+	err := eg.Note(&eg.Err{CauseErr: errors.New("cause")}, "annotated")
+
+	if err.Error() != "annotated: cause" {
+		t.Errorf("expected %q, got %q", "annotated: cause", err.Error())
+	}
+	if msg := eg.Enrich(errors.New("cause")).Error(); msg != "cause" {
+		t.Errorf("expected %q, got %q", "cause", msg)
+	}
+}
+
+func TestNoteAt(t *testing.T) {
+	err := eg.NoteAt(errors.New("undefined variable"), "render", "index.tmpl", 12, "rendering %s", "index")
+
+	expected := "[render@index.tmpl:12] rendering index\nundefined variable"
+	if details := eg.Details(err); details != expected {
+		t.Errorf("expected %q, got %q", expected, details)
+	}
+	if eg.NoteAt(nil, "render", "index.tmpl", 12, "rendering") != nil {
+		t.Error("expected nil for a nil error")
+	}
+}
+
+func TestStrippedLocations(t *testing.T) {
+	for _, tt := range []struct {
+		function, file string
+		line           int
+		want           string
+	}{
+		{"", "<autogenerated>", 1, "[optimized] msg"},
+		{"main.run", "<autogenerated>", 1, "[main.run] msg"},
+		{"", "main.go", 12, "[?@main.go:12] msg"},
+		{"", "", 0, "msg"},
+	} {
+		err := eg.NoteAt(eg.Lazy(""), tt.function, tt.file, tt.line, "msg")
+		if got := strings.Split(eg.Details(err), "\n")[0]; got != tt.want {
+			t.Errorf("%q %q %d: expected %q, got %q", tt.function, tt.file, tt.line, tt.want, got)
+		}
+	}
+	if got := eg.Lazy("").Location.String(); got != "[optimized]" {
+		t.Errorf("expected a placeholder for an empty location, got %q", got)
+	}
+}
+
+func TestNoteOr(t *testing.T) {
+	err := eg.NoteOr(nil, "no result for %s", "user")
+	e, ok := err.(*eg.Err)
+	if !ok || e.Message != "no result for user" || !strings.HasSuffix(e.Location.Function, "TestNoteOr") {
+		t.Errorf("expected a new Err from the message, got %#v", err)
+	}
+
+	cause := errors.New("timeout")
+	if err := eg.NoteOr(cause, "querying"); err.Error() != "querying: timeout" || !errors.Is(err, cause) {
+		t.Errorf("expected the error to be noted, got %v", err)
+	}
+}
+
+type bootstrapper struct{}
+
+func (*bootstrapper) start(err error) error { return eg.NoteFunc(err) }
+
+func TestNoteFunc(t *testing.T) {
+	err := eg.NoteFunc(errors.New("no config"))
+	if err.Error() != "TestNoteFunc: no config" {
+		t.Errorf("expected the caller's name as the message, got %q", err.Error())
+	}
+	if err := (&bootstrapper{}).start(eg.Error("no config")); err.Error() != "(*bootstrapper).start: no config" {
+		t.Errorf("expected the method's name as the message, got %q", err.Error())
+	}
+	if eg.NoteFunc(nil) != nil {
+		t.Error("expected nil for a nil error")
+	}
+}
+
+func TestNoteAll(t *testing.T) {
+	root := eg.Error("root")
+	err := eg.NoteAll(root, "user=7", "path=/login", "100% done")
+
+	if len(root.Annotations) != 3 {
+		t.Fatalf("expected 3 annotations, got %d", len(root.Annotations))
+	}
+	for x, want := range []string{"user=7", "path=/login", "100% done"} {
+		a := root.Annotations[x]
+		if a.Message != want {
+			t.Errorf("expected annotation %d to be %q, got %q", x, want, a.Message)
+		}
+		if first := root.Annotations[0]; a.File != first.File || a.Line != first.Line || a.Function != first.Function {
+			t.Errorf("expected annotation %d to share the first's location, got %s:%d", x, a.File, a.Line)
+		}
+	}
+	if err.Error() != "100% done: path=/login: user=7: root" {
+		t.Errorf("unexpected Error() %q", err.Error())
+	}
+}
+
+// depth returns the number of errors in err's chain.
+func depth(err error) int {
+	n := 0
+	for ok := true; err != nil && ok; err, ok = eg.Cause(err) {
+		n++
+	}
+	return n
+}
+
+func TestAlwaysWrap(t *testing.T) {
+	eg.AlwaysWrap = true
+	defer func() { eg.AlwaysWrap = false }()
+
+	for _, err := range []error{errors.New("plain"), eg.Error("eg")} {
+		start := depth(err)
+		err = eg.Note(err, "first")
+		err = eg.Note(err, "second")
+		if d := depth(err); d != start+2 {
+			t.Errorf("expected depth %d, got %d", start+2, d)
+		}
+		if e := err.(*eg.Err); e.Message != "second" || len(e.Annotations) != 0 {
+			t.Errorf("expected a new layer with message %q, got %q with %d annotations", "second", e.Message, len(e.Annotations))
+		}
+	}
+}
+
+func TestNilErr(t *testing.T) {
+	var e *eg.Err
+
+	if msg := e.Error(); msg != "" {
+		t.Errorf("expected empty Error(), got %q", msg)
+	}
+	if details := e.Details(); details != "" {
+		t.Errorf("expected empty Details(), got %q", details)
+	}
+	if cause := e.Cause(); cause != nil {
+		t.Errorf("expected nil Cause(), got %v", cause)
+	}
+	if err := e.Annotate("msg", "function", "file", 1); err != nil {
+		t.Errorf("expected nil from Annotate(), got %v", err)
+	}
+	if errors.Is(e, io.EOF) {
+		t.Error("expected nil *Err not to match io.EOF")
+	}
+	if e.StackTrace() != "" || e.SetCause(io.EOF) != nil || e.ReplaceCause(io.EOF) != nil || e.Clone() != nil {
+		t.Error("expected nil *Err methods to do nothing")
+	}
+	if b, err := e.MarshalJSON(); err != nil || string(b) != "null" {
+		t.Errorf("expected null JSON, got %s (%v)", b, err)
+	}
+	if _, err := e.MarshalBinary(); err == nil {
+		t.Error("expected an error encoding a nil *Err")
+	}
+	if v := e.LogValue(); len(v.Group()) != 0 {
+		t.Errorf("expected an empty log value, got %v", v)
+	}
+
+	// A typed nil flowing around as an error.
+	var err error = e
+	if _, ok := eg.Code(err); ok {
+		t.Error("expected no code")
+	}
+	if eg.Headline(err) != "" || eg.Summary(err) != "" || eg.Details(err) != "" {
+		t.Error("expected nothing to render")
+	}
+	wrapped := eg.Error("outer").SetCause(err)
+	if wrapped.Error() != "outer" || !strings.HasSuffix(eg.Details(wrapped), " outer") {
+		t.Errorf("expected a typed nil cause to render as nothing, got %q", wrapped.Error())
+	}
+	if _, jsonErr := wrapped.MarshalJSON(); jsonErr != nil {
+		t.Errorf("expected a typed nil cause to encode, got %v", jsonErr)
+	}
+	if eg.RootCause(wrapped) != wrapped {
+		t.Error("expected the typed nil cause to end the chain")
+	}
+
+	// Helpers that set fields wrap a typed nil rather than modifying it.
+	for name, fn := range map[string]func(error) error{
+		"WithArgs":      func(err error) error { return eg.WithArgs(err, 1) },
+		"WithRequestID": func(err error) error { return eg.WithRequestID(err, "req") },
+		"WithDuration":  func(err error) error { return eg.WithDuration(err, time.Second) },
+		"Warn":          eg.Warn,
+		"NoteEvent":     func(err error) error { return eg.NoteEvent(err, "retry", "retrying") },
+		"SetValue":      func(err error) error { return eg.SetValue(err, attemptsKey, 1) },
+		"Notekv":        func(err error) error { return eg.Notekv(err, "noted", "k", "v") },
+	} {
+		got, ok := fn(err).(*eg.Err)
+		if !ok || got == nil {
+			t.Errorf("%s: expected a new Err, got %#v", name, got)
+		}
+	}
+}
+
+func TestWithArgs(t *testing.T) {
+	err := eg.WithArgs(eg.Error("user not found"), 42, "bob")
+
+	if !strings.Contains(eg.Details(err), "] args=[42, bob]\n") {
+		t.Errorf("expected args in details, got %q", eg.Details(err))
+	}
+	if err.Error() != "user not found" {
+		t.Errorf("expected args not to appear in Error(), got %q", err.Error())
+	}
+
+	wrapped := eg.WithArgs(errors.New("user not found"), 42)
+	if !strings.HasSuffix(strings.Split(eg.Details(wrapped), "\n")[0], "] args=[42]") {
+		t.Errorf("expected args on the wrapper's line, got %q", eg.Details(wrapped))
+	}
+}
+
+func findUser() error {
+	return eg.Error("user not found")
+}
+
+func loadProfile() error {
+	return eg.WithArgs(eg.Note(findUser(), "loading profile"), "bob")
+}
+
+func TestWithArgsAfterNote(t *testing.T) {
+	err := eg.WithArgs(eg.Note(loadProfile(), "handling request"), 7)
+
+	lines := strings.Split(eg.Details(err), "\n")
+	if len(lines) != 5 {
+		t.Fatalf("expected 5 lines, got:\n%s", eg.Details(err))
+	}
+	if !strings.Contains(lines[0], "eg_test.TestWithArgsAfterNote@") || !strings.HasSuffix(lines[0], "] args=[7]") {
+		t.Errorf("expected the newest args against their caller, got %q", lines[0])
+	}
+	if !strings.Contains(lines[2], "eg_test.loadProfile@") || !strings.HasSuffix(lines[2], "] args=[bob]") {
+		t.Errorf("expected the earlier args against their caller, got %q", lines[2])
+	}
+	if !strings.HasSuffix(lines[4], "] user not found") {
+		t.Errorf("expected the root's line without args, got %q", lines[4])
+	}
+}
+
+func TestNewNoLoc(t *testing.T) {
+	err := eg.Note(eg.NewNoLoc("generated %d", 1), "annotated")
+
+	lines := strings.Split(eg.Details(err), "\n")
+	if len(lines) != 2 || lines[1] != "generated 1" {
+		t.Errorf("expected no location for the created error, got %q", lines)
+	}
+}
+
+func BenchmarkError(b *testing.B) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		_ = eg.Error("boom")
+	}
+}
+
+func BenchmarkNewNoLoc(b *testing.B) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		_ = eg.NewNoLoc("boom")
+	}
+}
+
+func TestSetCause(t *testing.T) {
+	cause := errors.New("cleanup failed")
+	err := eg.Error("shutdown").SetCause(cause)
+
+	if err.Error() != "shutdown: cleanup failed" {
+		t.Errorf("expected cause in Error(), got %q", err.Error())
+	}
+	if errors.Unwrap(err) != cause {
+		t.Errorf("expected Unwrap to return the cause, got %v", errors.Unwrap(err))
+	}
+
+	err.SetCause(errors.New("other"))
+	if err.Cause() != cause {
+		t.Errorf("expected existing cause to be kept, got %v", err.Cause())
+	}
+
+	other := errors.New("other")
+	if err.ReplaceCause(other).Cause() != other {
+		t.Errorf("expected cause to be replaced, got %v", err.Cause())
+	}
+}
+
+func TestIsEg(t *testing.T) {
+	if !eg.IsEg(eg.Error("direct")) {
+		t.Error("expected a direct *Err to be detected")
+	}
+	if !eg.IsEg(fmt.Errorf("wrapped: %w", eg.Error("nested"))) {
+		t.Error("expected a nested *Err to be detected")
+	}
+	if eg.IsEg(fmt.Errorf("wrapped: %w", errors.New("plain"))) {
+		t.Error("expected a plain error not to be detected")
+	}
+}
+
+func TestMaskType(t *testing.T) {
+	_, orig := os.Open("/does/not/exist")
+	err := eg.MaskType(orig)
+
+	if err.Error() != "internal error (type: *fs.PathError)" {
+		t.Errorf("expected the type name, got %q", err.Error())
+	}
+	if strings.Contains(eg.Details(err), "/does/not/exist") {
+		t.Errorf("expected the original message to be hidden, got %q", eg.Details(err))
+	}
+	if cause, _ := eg.Cause(err); cause != nil {
+		t.Errorf("expected no cause, got %v", cause)
+	}
+}
+
+// deepChain returns a chain of n errors, each with an annotation.
+func deepChain(n int) error {
+	return deepChainOver(eg.Error("root"), n)
+}
+
+// deepChainOver is like deepChain, but with root at the bottom of the chain.
+func deepChainOver(root error, n int) error {
+	err := eg.Note(root, "annotated root")
+	for x := 1; x < n; x++ {
+		err = eg.Note(eg.Error("layer %d", x).SetCause(err), "annotated")
+	}
+	return err
+}
+
+func TestDetailsDeepChain(t *testing.T) {
+	err := deepChain(50)
+
+	// Build the expected details the way Details used to, by joining each
+	// layer's rendered lines.
+	lines := []string{}
+	for e := err.(*eg.Err); e != nil; e, _ = e.CauseErr.(*eg.Err) {
+		for x := len(e.Annotations) - 1; x >= 0; x-- {
+			a := e.Annotations[x]
+			lines = append(lines, fmt.Sprintf("[%s@%s:%d] %s", a.Function, a.File, a.Line, a.Message))
+		}
+		lines = append(lines, e.Location.String()+" "+e.Message)
+	}
+	expected := strings.Join(lines, "\n")
+
+	if details := eg.Details(err); details != expected {
+		t.Errorf("expected:\n%s\ngot:\n%s", expected, details)
+	}
+}
+
+func BenchmarkDetailsDeepChain(b *testing.B) {
+	for _, bb := range []struct {
+		name string
+		err  error
+	}{
+		{"eg root", deepChain(50)},
+		{"foreign root", deepChainOver(errors.New("root"), 50)},
+	} {
+		b.Run(bb.name, func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				_ = eg.Details(bb.err)
+			}
+		})
+	}
+}
+
+func TestMask(t *testing.T) {
+	err := eg.Mask(errors.New("connection refused"), "not found")
+	if err.Error() != "not found: connection refused" {
+		t.Errorf("expected message to be prefixed, got %q", err.Error())
+	}
+	if cause, _ := eg.Cause(err); cause != nil {
+		t.Errorf("expected no cause, got %v", cause)
+	}
+}
+
+func maskAtBoundary(err error) error {
+	return eg.MaskWithStack(err, "lookup failed")
+}
+
+func TestMaskWithStack(t *testing.T) {
+	orig := eg.Error("pq: no rows")
+	err := maskAtBoundary(orig)
+	if errors.Unwrap(err) != nil {
+		t.Errorf("expected the original to be hidden, got %v", errors.Unwrap(err))
+	}
+
+	lines := strings.Split(eg.Details(err), "\n")
+	if len(lines) < 3 || !strings.Contains(lines[0], "eg_test.maskAtBoundary@") {
+		t.Fatalf("expected the mask site followed by its stack, got:\n%s", eg.Details(err))
+	}
+	if !strings.HasPrefix(lines[1], "\t[github.com/natefinch/eg_test.maskAtBoundary@") || !strings.Contains(lines[2], "TestMaskWithStack") {
+		t.Errorf("expected the mask site's stack in Details, got:\n%s", eg.Details(err))
+	}
+	if plain := eg.Mask(orig, "lookup failed"); strings.Count(eg.Details(plain), "\n") != 0 {
+		t.Errorf("expected Mask alone not to render its stack, got:\n%s", eg.Details(plain))
+	}
+}
+
+func TestMaskRedundant(t *testing.T) {
+	err := eg.Mask(errors.New("not found: user 7"), "not found")
+	if err.Error() != "not found: user 7" {
+		t.Errorf("expected message not to be repeated, got %q", err.Error())
+	}
+	if err := eg.Mask(errors.New("not found"), "not found"); err.Error() != "not found" {
+		t.Errorf("expected an identical message not to be repeated, got %q", err.Error())
+	}
+	if err := eg.Mask(errors.New("not foundation"), "not found"); err.Error() != "not found: not foundation" {
+		t.Errorf("expected a prefix that isn't a whole message to be kept, got %q", err.Error())
+	}
+}
+
+func TestAnnotateWith(t *testing.T) {
+	err := eg.Error("root")
+	err.AnnotateWith("querying", map[string]interface{}{"table": "users", "id": 7})
+	eg.Note(err, "loading")
+
+	lines := strings.Split(err.Details(), "\n")
+	if len(lines) != 5 {
+		t.Fatalf("expected 5 lines, got:\n%s", err.Details())
+	}
+	if !strings.HasSuffix(lines[0], " loading") || !strings.HasSuffix(lines[1], " querying") {
+		t.Errorf("expected the annotations first, got:\n%s", err.Details())
+	}
+	if lines[2] != "\tid=7" || lines[3] != "\ttable=users" {
+		t.Errorf("expected the fields beneath the querying line, got:\n%s", err.Details())
+	}
+	if err.Error() != "loading: querying: root" {
+		t.Errorf("expected fields to be left out of Error(), got %q", err.Error())
+	}
+}
+
+// wrapper has the same method set as xerrors.Wrapper.
+type wrapper interface {
+	Unwrap() error
+}
+
+func TestXerrorsWrapper(t *testing.T) {
+	cause := errors.New("disk full")
+	err := eg.Note(eg.Error("saving").SetCause(cause), "handling upload")
+
+	// Unwrap layer by layer, as xerrors.Unwrap does.
+	var got error = err
+	for {
+		w, ok := got.(wrapper)
+		if !ok || w.Unwrap() == nil {
+			break
+		}
+		got = w.Unwrap()
+	}
+	if got != cause {
+		t.Errorf("expected unwrapping to reach the cause, got %v", got)
+	}
+}
+
+// echoError renders the details of the error wrapping it, as a careless
+// implementation might.
+type echoError struct {
+	outer *error
+}
+
+func (e echoError) Error() string { return "echo: " + eg.Details(*e.outer) }
+
+func TestDetailsReentrantCause(t *testing.T) {
+	var err error
+	err = eg.Note(echoError{&err}, "wrapped")
+
+	done := make(chan string)
+	go func() { done <- eg.Details(err) }()
+	select {
+	case details := <-done:
+		if !strings.Contains(details, "(cycle detected)") {
+			t.Errorf("expected a cycle marker, got:\n%s", details)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Details did not terminate")
+	}
+}
+
+func TestOnError(t *testing.T) {
+	created := 0
+	eg.OnError = func(*eg.Err) { created++ }
+	defer func() { eg.OnError = nil }()
+
+	err := eg.Error("root")
+	if created != 1 {
+		t.Errorf("expected Error to fire the hook once, got %d", created)
+	}
+	eg.Note(err, "annotated")
+	if created != 1 {
+		t.Errorf("expected annotating not to fire the hook, got %d", created)
+	}
+	eg.Note(errors.New("plain"), "wrapped")
+	if created != 2 {
+		t.Errorf("expected wrapping to fire the hook once, got %d", created)
+	}
+	eg.Mask(err, "masked")
+	if created != 3 {
+		t.Errorf("expected Mask to fire the hook once, got %d", created)
+	}
+	eg.Errors("a", "b")
+	if created != 5 {
+		t.Errorf("expected Errors to fire the hook per message, got %d", created)
+	}
+}
+
+func TestOnErrorSeesFields(t *testing.T) {
+	byCode := map[string]int{}
+	eg.OnError = func(e *eg.Err) { byCode[e.Code]++ }
+	defer func() { eg.OnError = nil }()
+
+	eg.MaskCode(errors.New("no rows"), "NOT_FOUND", "loading user")
+	eg.MaskType(eg.MaskCode(errors.New("denied"), "FORBIDDEN", "checking access"))
+
+	if byCode["NOT_FOUND"] != 1 || byCode["FORBIDDEN"] != 2 || byCode[""] != 0 {
+		t.Errorf("expected the hook to see each error's code, got %v", byCode)
+	}
+
+	var seen []string
+	eg.OnError = func(e *eg.Err) { seen = append(seen, e.RequestID+fmt.Sprint(e.Context["k"])) }
+	eg.WithRequestID(errors.New("timeout"), "req-1")
+	eg.Notekv(errors.New("timeout"), "calling", "k", "v")
+	if len(seen) != 2 || seen[0] != "req-1<nil>" || seen[1] != "v" {
+		t.Errorf("expected one report per new Err, after its fields are set, got %q", seen)
+	}
+}
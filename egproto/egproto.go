@@ -0,0 +1,109 @@
+// Package egproto converts eg errors to and from a protobuf message, so that
+// their details can survive transports such as gRPC trailers.
+//
+// The messages are described by error.proto and are encoded by hand in the
+// protobuf wire format, so this package has no protobuf dependency.
+package egproto
+
+import (
+	"errors"
+
+	"github.com/natefinch/eg"
+)
+
+// ErrorProto is the protobuf representation of an eg.Err.
+type ErrorProto struct {
+	Message     string
+	Location    *LocationProto
+	Annotations []*AnnotationProto
+	Cause       *ErrorProto
+	Code        string
+}
+
+// LocationProto is the protobuf representation of a location in source code.
+type LocationProto struct {
+	Function string
+	File     string
+	Line     int64
+}
+
+// AnnotationProto is the protobuf representation of an annotation.
+type AnnotationProto struct {
+	Message  string
+	Location *LocationProto
+}
+
+// MaxDepth is the maximum number of errors in a chain converted by ToProto.
+// Deeper causes are replaced by one holding only a truncation message.
+var MaxDepth = 100
+
+// ToProto converts err and its chain of causes to an ErrorProto.  Errors that
+// are not eg.Errs are represented by their Error() string.  A nil *eg.Err
+// converts to nil, so it ends the chain.  Chains deeper than MaxDepth, or that
+// refer back to an earlier error, end with a cause holding only a message
+// saying why.
+func ToProto(err error) *ErrorProto {
+	return toProto(err, 0, map[*eg.Err]bool{})
+}
+
+func toProto(err error, depth int, seen map[*eg.Err]bool) *ErrorProto {
+	if err == nil {
+		return nil
+	}
+	if depth >= MaxDepth {
+		return &ErrorProto{Message: "(max depth exceeded)"}
+	}
+	e, ok := err.(*eg.Err)
+	if !ok {
+		return &ErrorProto{Message: err.Error()}
+	}
+	if e == nil {
+		return nil
+	}
+	if seen[e] {
+		return &ErrorProto{Message: "(cycle detected)"}
+	}
+	seen[e] = true
+
+	p := &ErrorProto{
+		Message:  e.Message,
+		Location: &LocationProto{e.Location.Function, e.Location.File, int64(e.Location.Line)},
+		Cause:    toProto(e.CauseErr, depth+1, seen),
+		Code:     e.Code,
+	}
+	for _, a := range e.Annotations {
+		p.Annotations = append(p.Annotations, &AnnotationProto{
+			Message:  a.Message,
+			Location: &LocationProto{a.Function, a.File, int64(a.Line)},
+		})
+	}
+	return p
+}
+
+// FromProto converts an ErrorProto back to an eg.Err.  Messages that were
+// converted from errors other than eg.Errs are restored as plain errors.
+func FromProto(p *ErrorProto) error {
+	if p == nil {
+		return nil
+	}
+	if p.Location == nil && len(p.Annotations) == 0 && p.Cause == nil && p.Code == "" {
+		return errors.New(p.Message)
+	}
+	e := &eg.Err{Message: p.Message, Code: p.Code}
+	if p.Location != nil {
+		e.Location.Function = p.Location.Function
+		e.Location.File = p.Location.File
+		e.Location.Line = int(p.Location.Line)
+	}
+	for _, a := range p.Annotations {
+		l := a.Location
+		if l == nil {
+			l = &LocationProto{}
+		}
+		e.Annotate(a.Message, l.Function, l.File, int(l.Line))
+	}
+	if p.Cause != nil {
+		e.CauseErr = FromProto(p.Cause)
+	}
+	return e
+}
@@ -0,0 +1,88 @@
+package egproto_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/natefinch/eg"
+	"github.com/natefinch/eg/egproto"
+)
+
+func TestRoundTrip(t *testing.T) {
+	inner := eg.Note(errors.New("file not found"), "reading config")
+	outer := &eg.Err{Message: "can't start", CauseErr: inner, Code: "config"}
+	err := eg.Note(outer, "bootstrapping")
+
+	b, merr := egproto.ToProto(err).Marshal()
+	if merr != nil {
+		t.Fatal(merr)
+	}
+	p := &egproto.ErrorProto{}
+	if uerr := p.Unmarshal(b); uerr != nil {
+		t.Fatal(uerr)
+	}
+	got := egproto.FromProto(p)
+
+	if got.Error() != err.Error() {
+		t.Errorf("expected %q, got %q", err.Error(), got.Error())
+	}
+	if eg.Details(got) != eg.Details(err) {
+		t.Errorf("expected details:\n%s\ngot:\n%s", eg.Details(err), eg.Details(got))
+	}
+	if code, _ := eg.Code(got); code != "config" {
+		t.Errorf("expected code %q, got %q", "config", code)
+	}
+}
+
+func TestUnmarshalTruncated(t *testing.T) {
+	b, _ := egproto.ToProto(eg.Error("boom")).Marshal()
+
+	p := &egproto.ErrorProto{}
+	if err := p.Unmarshal(b[:len(b)-1]); err == nil {
+		t.Error("expected an error for a truncated message")
+	}
+}
+
+func TestToProtoNilErr(t *testing.T) {
+	var e *eg.Err
+
+	if p := egproto.ToProto(e); p != nil {
+		t.Errorf("expected nil, got %#v", p)
+	}
+	p := egproto.ToProto(eg.Error("outer").SetCause(e))
+	if p == nil || p.Message != "outer" || p.Cause != nil {
+		t.Errorf("expected a typed nil cause to end the chain, got %#v", p)
+	}
+}
+
+// last returns the innermost cause of p and the length of its chain.
+func last(p *egproto.ErrorProto) (*egproto.ErrorProto, int) {
+	n := 1
+	for p.Cause != nil {
+		p = p.Cause
+		n++
+	}
+	return p, n
+}
+
+func TestToProtoCycle(t *testing.T) {
+	err := eg.Error("root")
+	err.CauseErr = &eg.Err{Message: "middle", CauseErr: err}
+
+	p, n := last(egproto.ToProto(err))
+	if n != 3 || p.Message != "(cycle detected)" {
+		t.Errorf("expected the cycle to be cut after 2 errors, got %d ending in %q", n, p.Message)
+	}
+}
+
+func TestToProtoDeepChain(t *testing.T) {
+	err := eg.Error("root")
+	for x := 0; x < 1000; x++ {
+		err = &eg.Err{Message: "layer", CauseErr: err}
+	}
+
+	p, n := last(egproto.ToProto(err))
+	if n != egproto.MaxDepth+1 || p.Message != "(max depth exceeded)" {
+		t.Errorf("expected a chain of %d ending in a marker, got %d ending in %q", egproto.MaxDepth+1, n, p.Message)
+	}
+}
@@ -0,0 +1,166 @@
+package egproto
+
+import (
+	"encoding/binary"
+	"errors"
+	"math"
+)
+
+// Wire types from the protobuf encoding.
+const (
+	wireVarint  = 0
+	wireFixed64 = 1
+	wireBytes   = 2
+	wireFixed32 = 5
+)
+
+var errTruncated = errors.New("egproto: truncated message")
+
+// Marshal encodes the error in the protobuf wire format.
+func (p *ErrorProto) Marshal() ([]byte, error) {
+	return p.append(nil), nil
+}
+
+// Unmarshal decodes the error from the protobuf wire format.
+func (p *ErrorProto) Unmarshal(b []byte) error {
+	*p = ErrorProto{}
+	return decode(b, func(num int, typ int, v uint64, data []byte) error {
+		switch {
+		case num == 1 && typ == wireBytes:
+			p.Message = string(data)
+		case num == 2 && typ == wireBytes:
+			p.Location = &LocationProto{}
+			return p.Location.unmarshal(data)
+		case num == 3 && typ == wireBytes:
+			a := &AnnotationProto{}
+			p.Annotations = append(p.Annotations, a)
+			return a.unmarshal(data)
+		case num == 4 && typ == wireBytes:
+			p.Cause = &ErrorProto{}
+			return p.Cause.Unmarshal(data)
+		case num == 5 && typ == wireBytes:
+			p.Code = string(data)
+		}
+		return nil
+	})
+}
+
+func (p *ErrorProto) append(b []byte) []byte {
+	b = appendString(b, 1, p.Message)
+	if p.Location != nil {
+		b = appendBytes(b, 2, p.Location.append(nil))
+	}
+	for _, a := range p.Annotations {
+		b = appendBytes(b, 3, a.append(nil))
+	}
+	if p.Cause != nil {
+		b = appendBytes(b, 4, p.Cause.append(nil))
+	}
+	return appendString(b, 5, p.Code)
+}
+
+func (l *LocationProto) append(b []byte) []byte {
+	b = appendString(b, 1, l.Function)
+	b = appendString(b, 2, l.File)
+	if l.Line != 0 {
+		b = binary.AppendUvarint(b, 3<<3|wireVarint)
+		b = binary.AppendUvarint(b, uint64(l.Line))
+	}
+	return b
+}
+
+func (l *LocationProto) unmarshal(b []byte) error {
+	return decode(b, func(num int, typ int, v uint64, data []byte) error {
+		switch {
+		case num == 1 && typ == wireBytes:
+			l.Function = string(data)
+		case num == 2 && typ == wireBytes:
+			l.File = string(data)
+		case num == 3 && typ == wireVarint:
+			l.Line = int64(v)
+		}
+		return nil
+	})
+}
+
+func (a *AnnotationProto) append(b []byte) []byte {
+	b = appendString(b, 1, a.Message)
+	if a.Location != nil {
+		b = appendBytes(b, 2, a.Location.append(nil))
+	}
+	return b
+}
+
+func (a *AnnotationProto) unmarshal(b []byte) error {
+	return decode(b, func(num int, typ int, v uint64, data []byte) error {
+		switch {
+		case num == 1 && typ == wireBytes:
+			a.Message = string(data)
+		case num == 2 && typ == wireBytes:
+			a.Location = &LocationProto{}
+			return a.Location.unmarshal(data)
+		}
+		return nil
+	})
+}
+
+// appendString appends a string field, omitting it if empty as proto3 does.
+func appendString(b []byte, num int, s string) []byte {
+	if s == "" {
+		return b
+	}
+	return appendBytes(b, num, []byte(s))
+}
+
+func appendBytes(b []byte, num int, data []byte) []byte {
+	b = binary.AppendUvarint(b, uint64(num)<<3|wireBytes)
+	b = binary.AppendUvarint(b, uint64(len(data)))
+	return append(b, data...)
+}
+
+// decode calls fn for each field in b.  Varint fields are passed in v and
+// length-delimited fields in data.  Fixed-width fields are skipped.
+func decode(b []byte, fn func(num int, typ int, v uint64, data []byte) error) error {
+	for len(b) > 0 {
+		tag, n := binary.Uvarint(b)
+		if n <= 0 || tag>>3 > math.MaxInt32 {
+			return errTruncated
+		}
+		b = b[n:]
+		num, typ := int(tag>>3), int(tag&7)
+
+		var v uint64
+		var data []byte
+		switch typ {
+		case wireVarint:
+			v, n = binary.Uvarint(b)
+			if n <= 0 {
+				return errTruncated
+			}
+			b = b[n:]
+		case wireBytes:
+			size, n := binary.Uvarint(b)
+			if n <= 0 || uint64(len(b)-n) < size {
+				return errTruncated
+			}
+			data = b[n : n+int(size)]
+			b = b[n+int(size):]
+		case wireFixed64, wireFixed32:
+			size := 8
+			if typ == wireFixed32 {
+				size = 4
+			}
+			if len(b) < size {
+				return errTruncated
+			}
+			b = b[size:]
+			continue
+		default:
+			return errors.New("egproto: unsupported wire type")
+		}
+		if err := fn(num, typ, v, data); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,45 @@
+// Package egtest provides test helpers for code that returns eg errors.  On
+// failure, the helpers print the error's full details so the failure can be
+// debugged from the test output.
+package egtest
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/natefinch/eg"
+)
+
+// RequireCause fails the test immediately unless target is in err's chain, as
+// determined by errors.Is.
+func RequireCause(t testing.TB, err, target error) {
+	t.Helper()
+	if !errors.Is(err, target) {
+		t.Fatalf("expected error caused by %q, got:\n%s", target, details(err))
+	}
+}
+
+// RequireCode fails the test immediately unless err's nearest code, as
+// returned by eg.Code, is code.
+func RequireCode(t testing.TB, err error, code string) {
+	t.Helper()
+	if got, _ := eg.Code(err); got != code {
+		t.Fatalf("expected error with code %q, got code %q:\n%s", code, got, details(err))
+	}
+}
+
+// NoError fails the test immediately, printing err's full details, if err is
+// not nil.
+func NoError(t testing.TB, err error) {
+	t.Helper()
+	if err != nil {
+		t.Fatalf("unexpected error:\n%s", details(err))
+	}
+}
+
+func details(err error) string {
+	if err == nil {
+		return "<nil>"
+	}
+	return eg.Details(err)
+}
@@ -0,0 +1,86 @@
+package egtest_test
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/natefinch/eg"
+	"github.com/natefinch/eg/egtest"
+)
+
+// fakeTB records failures instead of failing the test.
+type fakeTB struct {
+	testing.TB
+	failed  bool
+	msg     string
+	helpers int
+}
+
+func (f *fakeTB) Helper() { f.helpers++ }
+
+func (f *fakeTB) Fatalf(format string, args ...interface{}) {
+	f.failed = true
+	f.msg = fmt.Sprintf(format, args...)
+}
+
+func TestRequireCause(t *testing.T) {
+	root := errors.New("root")
+	err := eg.Note(root, "annotated")
+
+	f := &fakeTB{}
+	egtest.RequireCause(f, err, root)
+	if f.failed {
+		t.Errorf("expected pass, got failure: %s", f.msg)
+	}
+
+	f = &fakeTB{}
+	egtest.RequireCause(f, err, errors.New("other"))
+	if !f.failed {
+		t.Fatal("expected failure")
+	}
+	if !strings.Contains(f.msg, eg.Details(err)) {
+		t.Errorf("expected failure to include details, got %q", f.msg)
+	}
+}
+
+func TestRequireCode(t *testing.T) {
+	err := eg.Note(&eg.Err{Message: "not found", Code: "missing"}, "loading")
+
+	f := &fakeTB{}
+	egtest.RequireCode(f, err, "missing")
+	if f.failed {
+		t.Errorf("expected pass, got failure: %s", f.msg)
+	}
+
+	f = &fakeTB{}
+	egtest.RequireCode(f, err, "other")
+	if !f.failed {
+		t.Fatal("expected failure")
+	}
+	if !strings.Contains(f.msg, eg.Details(err)) {
+		t.Errorf("expected failure to include details, got %q", f.msg)
+	}
+}
+
+func TestNoError(t *testing.T) {
+	f := &fakeTB{}
+	egtest.NoError(f, nil)
+	if f.failed {
+		t.Errorf("expected pass, got failure: %s", f.msg)
+	}
+
+	err := eg.Note(eg.Error("disk full"), "saving")
+	f = &fakeTB{}
+	egtest.NoError(f, err)
+	if !f.failed {
+		t.Fatal("expected failure")
+	}
+	if !strings.Contains(f.msg, eg.Details(err)) {
+		t.Errorf("expected failure to include details, got %q", f.msg)
+	}
+	if f.helpers == 0 {
+		t.Error("expected NoError to mark itself as a helper")
+	}
+}
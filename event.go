@@ -0,0 +1,38 @@
+package eg
+
+// NoteEvent annotates err with msg, like Note, and tags the annotation with a
+// machine-stable event name, such as "db.query.timeout", for use as a metric
+// name.  The message is rendered as usual and the event is not.  If err is not
+// an Err, it is wrapped in one.
+func NoteEvent(err error, event, msg string) error {
+	if err == nil {
+		return nil
+	}
+	e := toErr(err, 1)
+	e.addAnnotation(annotation{
+		Message:   msg,
+		location:  locate(1),
+		Goroutine: goroutineID(),
+		Event:     event,
+	})
+	return reported(e, err)
+}
+
+// Events returns the event names attached by NoteEvent anywhere in err's chain,
+// newest first.
+func Events(err error) []string {
+	var events []string
+	walk(err, func(err error) bool {
+		e, ok := err.(*Err)
+		if !ok {
+			return true
+		}
+		for x := len(e.Annotations) - 1; x >= 0; x-- {
+			if ev := e.Annotations[x].Event; ev != "" {
+				events = append(events, ev)
+			}
+		}
+		return true
+	})
+	return events
+}
@@ -0,0 +1,26 @@
+package eg_test
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/natefinch/eg"
+)
+
+func TestEvents(t *testing.T) {
+	err := eg.NoteEvent(errors.New("i/o timeout"), "db.query.timeout", "query timed out after 5s")
+	err = eg.Note(err, "loading user")
+	err = eg.NoteEvent(eg.Note(err, "wrapped"), "http.request.failed", "request failed")
+
+	if got, want := eg.Events(err), []string{"http.request.failed", "db.query.timeout"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("expected events %v, got %v", want, got)
+	}
+	want := "request failed: wrapped: loading user: query timed out after 5s: i/o timeout"
+	if err.Error() != want {
+		t.Errorf("expected %q, got %q", want, err.Error())
+	}
+	if eg.NoteEvent(nil, "x", "y") != nil {
+		t.Error("expected nil for a nil error")
+	}
+}
@@ -0,0 +1,13 @@
+package eg
+
+import (
+	"time"
+)
+
+// SetNow replaces the clock used by ShouldLog with f, returning a function that
+// restores it.
+func SetNow(f func() time.Time) (restore func()) {
+	prev := now
+	now = f
+	return func() { now = prev }
+}
@@ -0,0 +1,50 @@
+package eg
+
+import (
+	"fmt"
+	"hash/fnv"
+	"strconv"
+)
+
+// fingerprint returns a hash identifying err by the messages and locations in
+// its chain, so that errors created the same way by the same code have the
+// same fingerprint.  Annotations are included, but stacks, IDs, and context
+// are not.
+func fingerprint(err error) uint64 {
+	h := fnv.New64a()
+	write := func(s string) {
+		h.Write([]byte(s))
+		h.Write([]byte{0})
+	}
+	writeLoc := func(l location) {
+		write(l.Function)
+		write(l.File)
+		write(strconv.Itoa(l.Line))
+	}
+	walk(err, func(err error) bool {
+		e, ok := err.(*Err)
+		if !ok {
+			write(err.Error())
+			return true
+		}
+		for _, a := range e.Annotations {
+			write(a.Message)
+			writeLoc(a.location)
+		}
+		write(e.Message)
+		writeLoc(e.Location)
+		return true
+	})
+	return h.Sum64()
+}
+
+// ShortID returns a short hex string derived from err's fingerprint, for
+// correlating the log lines of related errors.  Unlike ID, which is unique to
+// each error, it is the same for every error with the same messages created
+// and annotated at the same locations.  It returns an empty string for nil.
+func ShortID(err error) string {
+	if err == nil {
+		return ""
+	}
+	return fmt.Sprintf("%06x", fingerprint(err)&0xffffff)
+}
@@ -0,0 +1,37 @@
+package eg_test
+
+import (
+	"testing"
+
+	"github.com/natefinch/eg"
+)
+
+func fetch() error {
+	return eg.Note(eg.Error("timeout"), "fetching")
+}
+
+func TestShortID(t *testing.T) {
+	a, b := fetch(), fetch()
+	if eg.ShortID(a) != eg.ShortID(b) {
+		t.Errorf("expected identical errors to share a ShortID, got %q and %q", eg.ShortID(a), eg.ShortID(b))
+	}
+	if idOf(a) == "" || idOf(a) == idOf(b) {
+		t.Error("expected the errors to still have distinct IDs")
+	}
+	if len(eg.ShortID(a)) != 6 {
+		t.Errorf("expected 6 hex digits, got %q", eg.ShortID(a))
+	}
+
+	other := eg.Note(fetch(), "retrying")
+	if eg.ShortID(other) == eg.ShortID(a) {
+		t.Errorf("expected a different path to have a different ShortID, got %q", eg.ShortID(other))
+	}
+	if eg.ShortID(nil) != "" {
+		t.Error("expected no ShortID for nil")
+	}
+}
+
+func idOf(err error) string {
+	id, _ := eg.ID(err)
+	return id
+}
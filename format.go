@@ -0,0 +1,411 @@
+package eg
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// Separator is placed between an error's annotations and message by Error.
+var Separator = ": "
+
+// CauseSeparator is placed between an error's messages and its cause by Error.
+var CauseSeparator = ": "
+
+// AnnotationsInError controls whether Error includes annotations.  If false,
+// Error renders only messages and causes, and annotations appear only in
+// Details.
+var AnnotationsInError = true
+
+// LocationInError controls whether Error includes the location where each Err
+// was created, after its message, such as "not found [main.load@main.go:10]",
+// for quick triage in grep-able logs.
+var LocationInError = false
+
+// Order is the order in which each error's annotations are rendered.
+type Order int
+
+const (
+	// NewestFirst renders the most recently added annotation first, reading
+	// from where the error was last handled back to where it broke.
+	NewestFirst Order = iota
+
+	// OldestFirst renders annotations in the order they were added, as a
+	// chronological narrative.
+	OldestFirst
+)
+
+// AnnotationOrder is the order in which Error, Details, and RangeAnnotations
+// present each error's annotations.
+var AnnotationOrder = NewestFirst
+
+// EmptyMessageFallback is rendered by Error in place of an error that would
+// otherwise render as an empty string, such as one created with an empty
+// message and no cause, so that blank errors don't go unnoticed in logs.
+var EmptyMessageFallback = "unknown error"
+
+// MaxMessageLen is the maximum number of runes of each message rendered by
+// Error and Details.  Longer messages are truncated and end with "…".  The
+// stored messages are unchanged.  Zero or less means unlimited.
+var MaxMessageLen = 0
+
+// truncate shortens msg to MaxMessageLen runes.
+func truncate(msg string) string {
+	if MaxMessageLen <= 0 {
+		return msg
+	}
+	n := 0
+	for x := range msg {
+		if n == MaxMessageLen {
+			return msg[:x] + "…"
+		}
+		n++
+	}
+	return msg
+}
+
+// oneLine escapes line breaks in msg, so that Error() stays on a single line
+// even when a message or a foreign cause spans several.
+func oneLine(msg string) string {
+	if !strings.ContainsAny(msg, "\r\n") {
+		return msg
+	}
+	return lineEscaper.Replace(msg)
+}
+
+var lineEscaper = strings.NewReplacer("\r\n", `\n`, "\n", `\n`, "\r", `\r`)
+
+// LogLine returns err's Error() string with every control character, such as
+// tabs and line breaks, replaced by its escape sequence, so that the result is
+// always a single line of printable text that can be embedded in a logfmt or
+// JSON value.
+func LogLine(err error) string {
+	if err == nil {
+		return ""
+	}
+	msg := err.Error()
+	b := strings.Builder{}
+	for _, r := range msg {
+		if !unicode.IsControl(r) {
+			b.WriteRune(r)
+			continue
+		}
+		switch r {
+		case '\n':
+			b.WriteString(`\n`)
+		case '\r':
+			b.WriteString(`\r`)
+		case '\t':
+			b.WriteString(`\t`)
+		default:
+			q := strconv.QuoteRune(r)
+			b.WriteString(q[1 : len(q)-1])
+		}
+	}
+	return b.String()
+}
+
+// indent indents the continuation lines of a multi-line msg with a tab, so
+// they read as part of the line they belong to in Details.
+func indent(msg string) string {
+	if !strings.ContainsAny(msg, "\r\n") {
+		return msg
+	}
+	msg = strings.ReplaceAll(msg, "\r\n", "\n")
+	return strings.ReplaceAll(msg, "\n", "\n\t")
+}
+
+// Tree returns err's messages one per line, outermost first, with each line
+// indented beneath the one before it, like a chain of "because"s.  Unlike
+// Details, it doesn't include locations.
+func Tree(err error) string {
+	lines := []string{}
+	for x, msg := range segments(err) {
+		lines = append(lines, strings.Repeat("  ", x)+msg)
+	}
+	return strings.Join(lines, "\n")
+}
+
+// segments returns the non-empty messages of err's chain in the order they
+// are rendered by Error.
+func segments(err error) []string {
+	msgs := []string{}
+	for err != nil {
+		e, ok := err.(*Err)
+		if !ok {
+			return append(msgs, truncate(err.Error()))
+		}
+		if e == nil {
+			break
+		}
+		for x := range e.Annotations {
+			if msg := e.Annotations[e.ordered(x)].String(); msg != "" {
+				msgs = append(msgs, msg)
+			}
+		}
+		if e.Message != "" {
+			msgs = append(msgs, truncate(e.Message))
+		}
+		err = e.CauseErr
+	}
+	return msgs
+}
+
+// FormatPolicy controls what happens when a message's formatting verbs don't
+// match its arguments, such as "id %d" with a string argument, which fmt
+// renders as "id %!d(string=abc)".
+type FormatPolicy int
+
+const (
+	// IgnoreFormatErrors leaves fmt's output as is.
+	IgnoreFormatErrors FormatPolicy = iota
+
+	// MarkFormatErrors appends " (FORMAT ERROR)" to the message, so the bug
+	// is obvious.
+	MarkFormatErrors
+
+	// PanicOnFormatErrors panics, which is useful in tests.
+	PanicOnFormatErrors
+)
+
+// FormatErrors is the FormatPolicy used when formatting messages.
+var FormatErrors = IgnoreFormatErrors
+
+// sprintf formats msg with args, applying the FormatErrors policy.
+func sprintf(msg string, args ...interface{}) string {
+	s := fmt.Sprintf(msg, args...)
+	if FormatErrors == IgnoreFormatErrors || !badFormat(s, msg, args) {
+		return s
+	}
+	if FormatErrors == PanicOnFormatErrors {
+		panic(fmt.Sprintf("eg: bad format %q in message %q", msg, s))
+	}
+	return s + " (FORMAT ERROR)"
+}
+
+// badFormat reports whether s, the result of formatting args with msg, holds
+// one of fmt's "%!" error markers, ignoring any that came from the text of args
+// rendered by %v, %s, or %q, such as a user's input of "50%!".
+func badFormat(s, msg string, args []interface{}) bool {
+	n := strings.Count(s, "%!")
+	if n == 0 {
+		return false
+	}
+	for _, arg := range textArgs(msg, args) {
+		n -= strings.Count(fmt.Sprint(arg), "%!")
+	}
+	return n > 0
+}
+
+// textArgs returns the args that msg formats with %v, %s, or %q, which render
+// the text of the arg itself.
+func textArgs(msg string, args []interface{}) []interface{} {
+	var text []interface{}
+	arg := 0
+	for i := 0; i < len(msg); i++ {
+		if msg[i] != '%' {
+			continue
+		}
+		// skip flags, width, and precision, following any explicit indexes
+	spec:
+		for i++; i < len(msg); i++ {
+			switch c := msg[i]; {
+			case c == '*':
+				arg++
+			case c == '[':
+				end := strings.IndexByte(msg[i:], ']')
+				if end < 0 {
+					break spec
+				}
+				if x, err := strconv.Atoi(msg[i+1 : i+end]); err == nil {
+					arg = x - 1
+				}
+				i += end
+			case strings.IndexByte("+-# 0.", c) >= 0, '1' <= c && c <= '9':
+			default:
+				break spec
+			}
+		}
+		if i >= len(msg) || msg[i] == '%' {
+			continue
+		}
+		if strings.IndexByte("vsq", msg[i]) >= 0 && arg >= 0 && arg < len(args) {
+			text = append(text, args[arg])
+		}
+		arg++
+	}
+	return text
+}
+
+// DetailsByFile returns the lines recorded by err's chain, grouped by source
+// file, such as "foo.go: lines 10, 25, 40".  Files are listed in the order they
+// first appear in Details.  It is useful when an error passes through the same
+// file many times.
+func DetailsByFile(err error) string {
+	files := []string{}
+	lines := map[string][]string{}
+	rangeLocations(err, func(l location) {
+		if l == (location{}) {
+			return
+		}
+		file := strings.TrimPrefix(l.File, trimPrefix)
+		if _, ok := lines[file]; !ok {
+			files = append(files, file)
+		}
+		lines[file] = append(lines[file], strconv.Itoa(l.Line))
+	})
+
+	msgs := []string{}
+	for _, file := range files {
+		if len(lines[file]) == 1 {
+			msgs = append(msgs, file+": line "+lines[file][0])
+		} else {
+			msgs = append(msgs, file+": lines "+strings.Join(lines[file], ", "))
+		}
+	}
+	return strings.Join(msgs, "\n")
+}
+
+// Locations returns every location recorded in err's chain, in the order they
+// are rendered by Details: each error's annotations and then where it was
+// created, from the outermost error to the root.  Errors without a location,
+// such as sentinels made with Lazy, are skipped.  It is intended for tools that
+// visualize where errors originate and travel.
+func Locations(err error) []struct {
+	Function, File string
+	Line           int
+} {
+	var locs []struct {
+		Function, File string
+		Line           int
+	}
+	rangeLocations(err, func(l location) {
+		if l != (location{}) {
+			locs = append(locs, l)
+		}
+	})
+	return locs
+}
+
+// rangeLocations calls fn with each location recorded in err's chain, in the
+// order they are rendered by Details.
+func rangeLocations(err error, fn func(location)) {
+	walk(err, func(err error) bool {
+		if e, ok := err.(*Err); ok {
+			for x := range e.Annotations {
+				fn(e.Annotations[e.ordered(x)].location)
+			}
+			fn(e.Location)
+		}
+		return true
+	})
+}
+
+// Formatter renders errors with options that differ from the package-level
+// settings, without changing them for everyone else.  The zero Formatter
+// renders the same as the package-level functions.
+type Formatter struct {
+	// ShortLocations renders only the base name of each file in locations,
+	// such as [pkg.Baz@eg.go:42].
+	ShortLocations bool
+}
+
+// Details returns err's details, as with the package's Details function,
+// rendered with the formatter's options.
+func (f Formatter) Details(err error) string {
+	if e, ok := err.(*Err); ok && e != nil {
+		return e.header() + e.details(&style{short: f.ShortLocations})
+	}
+	return Details(err)
+}
+
+// plain is the default style.
+var plain = &style{}
+
+// style controls how the lines of an error's details are rendered.  The color
+// fields hold ANSI escape codes, and are empty for no color.  A nil style
+// renders the default plain details.
+type style struct {
+	location string
+	message  string
+	code     string
+	short    bool
+}
+
+// writeLine writes a single line of details to w.  Codes are only rendered in
+// color, and empty locations aren't rendered at all.
+func (s *style) writeLine(w *lineWriter, l location, msg, code string) {
+	if s == nil {
+		s = plain
+	}
+	w.Line()
+	if l != (location{}) {
+		if s.short {
+			l.File = filepath.Base(l.File)
+		}
+		w.WriteString(s.location)
+		l.writeTo(&w.Builder)
+		w.reset(s.location)
+		w.WriteByte(' ')
+	}
+	w.WriteString(s.message)
+	w.WriteString(msg)
+	w.reset(s.message)
+	if code != "" && s.code != "" {
+		w.WriteByte(' ')
+		w.WriteString(s.code)
+		w.WriteString("[" + code + "]")
+		w.reset(s.code)
+	}
+}
+
+// writeFields writes each of fields on its own indented line, sorted by key.
+func writeFields(w *lineWriter, fields map[string]interface{}) {
+	keys := make([]string, 0, len(fields))
+	for k := range fields {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		w.Line()
+		w.WriteString("\t" + k + "=" + fmt.Sprint(fields[k]))
+	}
+}
+
+// lineWriter builds multi-line output, separating lines with newlines.
+type lineWriter struct {
+	strings.Builder
+	lines int
+}
+
+// Line starts a new line.
+func (w *lineWriter) Line() {
+	if w.lines > 0 {
+		w.WriteByte('\n')
+	}
+	w.lines++
+}
+
+// reset ends the ANSI color code, if any.
+func (w *lineWriter) reset(color string) {
+	if color != "" {
+		w.WriteString(ansiReset)
+	}
+}
+
+// Dedent returns err's Error() string with one leading message equal to prefix
+// removed, such as for cleaning up context that was added twice before
+// displaying it.  The error itself is not changed.
+func Dedent(err error, prefix string) string {
+	if err == nil {
+		return ""
+	}
+	s := err.Error()
+	if s == prefix {
+		return ""
+	}
+	return strings.TrimPrefix(s, prefix+Separator)
+}
@@ -0,0 +1,291 @@
+package eg_test
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"unicode"
+
+	"github.com/natefinch/eg"
+)
+
+func TestMaxMessageLen(t *testing.T) {
+	eg.MaxMessageLen = 5
+	defer func() { eg.MaxMessageLen = 0 }()
+
+	err := eg.Note(errors.New("héllö wörld"), "ünïcödé message")
+	err = eg.Note(err, "ok")
+
+	expected := "ok: ünïcö…: héllö…"
+	if err.Error() != expected {
+		t.Errorf("expected %q, got %q", expected, err.Error())
+	}
+	if !strings.HasSuffix(eg.Details(err), "] ünïcö…\nhéllö…") {
+		t.Errorf("expected truncated details, got %q", eg.Details(err))
+	}
+	if e := err.(*eg.Err); e.Message != "ünïcödé message" {
+		t.Errorf("expected stored message to be unchanged, got %q", e.Message)
+	}
+}
+
+func TestTree(t *testing.T) {
+	err := eg.Note(errors.New("file not found"), "can't start foo")
+	err = &eg.Err{Message: "can't bootstrap", CauseErr: err}
+
+	expected := "can't bootstrap\n  can't start foo\n    file not found"
+	if tree := eg.Tree(err); tree != expected {
+		t.Errorf("expected:\n%s\ngot:\n%s", expected, tree)
+	}
+}
+
+func TestCauseSeparator(t *testing.T) {
+	eg.CauseSeparator = " caused by: "
+	defer func() { eg.CauseSeparator = ": " }()
+
+	err := eg.Note(eg.Note(errors.New("root"), "inner"), "outer")
+	err = eg.Note(&eg.Err{Message: "top", CauseErr: err}, "noted")
+
+	expected := "noted: top caused by: outer: inner caused by: root"
+	if err.Error() != expected {
+		t.Errorf("expected %q, got %q", expected, err.Error())
+	}
+}
+
+func TestAnnotationsInError(t *testing.T) {
+	eg.AnnotationsInError = false
+	defer func() { eg.AnnotationsInError = true }()
+
+	err := eg.Note(eg.Note(errors.New("root"), "message"), "annotation")
+
+	if err.Error() != "message: root" {
+		t.Errorf("expected %q, got %q", "message: root", err.Error())
+	}
+	if !strings.Contains(eg.Details(err), "] annotation\n") {
+		t.Errorf("expected details to include the annotation, got %q", eg.Details(err))
+	}
+}
+
+func TestMarkFormatErrors(t *testing.T) {
+	eg.FormatErrors = eg.MarkFormatErrors
+	defer func() { eg.FormatErrors = eg.IgnoreFormatErrors }()
+
+	// Passing the arguments as a slice keeps vet from catching the mistake.
+	args := []interface{}{"not-an-int"}
+	err := eg.Note(errors.New("root"), "id %d", args...)
+	expected := "id %!d(string=not-an-int) (FORMAT ERROR): root"
+	if err.Error() != expected {
+		t.Errorf("expected %q, got %q", expected, err.Error())
+	}
+	if err := eg.Error("id %d", 1); err.Error() != "id 1" {
+		t.Errorf("expected correct formats to be unchanged, got %q", err.Error())
+	}
+}
+
+func TestPanicOnFormatErrors(t *testing.T) {
+	eg.FormatErrors = eg.PanicOnFormatErrors
+	defer func() { eg.FormatErrors = eg.IgnoreFormatErrors }()
+
+	defer func() {
+		if recover() == nil {
+			t.Error("expected a panic")
+		}
+	}()
+	args := []interface{}{"not-an-int"}
+	eg.Error("id %d", args...)
+}
+
+func TestFormatErrorsIgnoreArgText(t *testing.T) {
+	defer func() { eg.FormatErrors = eg.IgnoreFormatErrors }()
+
+	for _, policy := range []eg.FormatPolicy{eg.MarkFormatErrors, eg.PanicOnFormatErrors} {
+		eg.FormatErrors = policy
+		if err := eg.Error("got %q", "50%!"); err.Error() != `got "50%!"` {
+			t.Errorf("expected an argument containing %%! not to be flagged, got %q", err.Error())
+		}
+		if err := eg.Error("user said %s", "wow%!"); err.Error() != "user said wow%!" {
+			t.Errorf("expected an argument containing %%! not to be flagged, got %q", err.Error())
+		}
+	}
+	eg.FormatErrors = eg.MarkFormatErrors
+	args := []interface{}{"wow%!"}
+	if err := eg.Error("id %d", args...); !strings.HasSuffix(err.Error(), " (FORMAT ERROR)") {
+		t.Errorf("expected a real mismatch to still be flagged, got %q", err.Error())
+	}
+	// %x doesn't render the arg's text, so its %! can't hide the missing arg.
+	if err := eg.Error("%x %d", args...); !strings.HasSuffix(err.Error(), " (FORMAT ERROR)") {
+		t.Errorf("expected a missing arg to be flagged, got %q", err.Error())
+	}
+	if err := eg.Error("%[2]s %[1]d", 7, "wow%!"); strings.HasSuffix(err.Error(), " (FORMAT ERROR)") {
+		t.Errorf("expected an indexed argument containing %%! not to be flagged, got %q", err.Error())
+	}
+}
+
+func TestDetailsByFile(t *testing.T) {
+	err := eg.NoteAt(errors.New("root"), "a", "/src/a.go", 10, "first")
+	err = eg.NoteAt(err, "b", "/src/b.go", 5, "second")
+	err = eg.NoteAt(err, "a", "/src/a.go", 25, "third")
+	err = eg.NoteAt(err, "a", "/src/a.go", 40, "fourth")
+
+	expected := "/src/a.go: lines 40, 25, 10\n/src/b.go: line 5"
+	if got := eg.DetailsByFile(err); got != expected {
+		t.Errorf("expected:\n%s\ngot:\n%s", expected, got)
+	}
+}
+
+func TestFormatterShortLocations(t *testing.T) {
+	err := eg.NoteAt(errors.New("root"), "pkg.Baz", "/home/user/src/pkg/eg.go", 42, "noted")
+
+	expected := "[pkg.Baz@eg.go:42] noted\nroot"
+	if got := (eg.Formatter{ShortLocations: true}).Details(err); got != expected {
+		t.Errorf("expected %q, got %q", expected, got)
+	}
+	if got := (eg.Formatter{}).Details(err); got != eg.Details(err) {
+		t.Errorf("expected the zero Formatter to match Details, got %q", got)
+	}
+}
+
+func TestDedent(t *testing.T) {
+	err := eg.Note(eg.Note(errors.New("not found"), "db"), "db")
+
+	if got := eg.Dedent(err, "db"); got != "db: not found" {
+		t.Errorf("expected prefix to be removed once, got %q", got)
+	}
+	if got := eg.Dedent(err, "cache"); got != err.Error() {
+		t.Errorf("expected non-matching prefix to be left alone, got %q", got)
+	}
+	if err.Error() != "db: db: not found" {
+		t.Errorf("expected error to be unchanged, got %q", err.Error())
+	}
+}
+
+func TestLocationInError(t *testing.T) {
+	err := eg.NoteAt(errors.New("root"), "main.load", "main.go", 10, "loading")
+	if err.Error() != "loading: root" {
+		t.Errorf("expected no location by default, got %q", err.Error())
+	}
+
+	eg.LocationInError = true
+	defer func() { eg.LocationInError = false }()
+
+	if err.Error() != "loading [main.load@main.go:10]: root" {
+		t.Errorf("expected location in Error(), got %q", err.Error())
+	}
+	if details := eg.Details(err); details != "[main.load@main.go:10] loading\nroot" {
+		t.Errorf("expected details to be unchanged, got %q", details)
+	}
+}
+
+func TestMultiLineCause(t *testing.T) {
+	err := eg.Note(errors.New("name: required\nage: too low"), "invalid form")
+
+	if got := err.Error(); got != `invalid form: name: required\nage: too low` {
+		t.Errorf("expected the newline to be escaped, got %q", got)
+	}
+	lines := strings.SplitN(eg.Details(err), "\n", 2)
+	if !strings.HasSuffix(lines[0], "invalid form") || lines[1] != "name: required\n\tage: too low" {
+		t.Errorf("expected the cause's second line to be indented, got:\n%s", eg.Details(err))
+	}
+}
+
+func TestLogLine(t *testing.T) {
+	err := eg.Note(errors.New("bad\tinput\nsee\x00docs"), "parsing\trequest")
+
+	got := eg.LogLine(err)
+	for _, r := range got {
+		if unicode.IsControl(r) {
+			t.Fatalf("expected no control characters, got %q", got)
+		}
+	}
+	if want := `parsing\trequest: bad\tinput\nsee\x00docs`; got != want {
+		t.Errorf("expected %q, got %q", want, got)
+	}
+}
+
+func TestEmptyMessageFallback(t *testing.T) {
+	if got := eg.Error("").Error(); got != "unknown error" {
+		t.Errorf("expected the fallback for an empty error, got %q", got)
+	}
+	if got := eg.Note(eg.Error(""), "").Error(); got != "unknown error" {
+		t.Errorf("expected the fallback for an empty annotation, got %q", got)
+	}
+	if got := eg.Note(eg.Error(""), "loading").Error(); got != "loading" {
+		t.Errorf("expected no fallback with an annotation, got %q", got)
+	}
+
+	eg.EmptyMessageFallback = "(no message)"
+	defer func() { eg.EmptyMessageFallback = "unknown error" }()
+	if got := eg.Mask(eg.Error(""), "").Error(); got != "(no message)" {
+		t.Errorf("expected the configured fallback, got %q", got)
+	}
+}
+
+func TestAnnotationOrder(t *testing.T) {
+	err := eg.Error("root")
+	for _, msg := range []string{"first", "second", "third"} {
+		eg.Note(err, msg)
+	}
+	messages := func() string {
+		lines := strings.Split(err.Details(), "\n")
+		for x, line := range lines {
+			lines[x] = line[strings.LastIndex(line, " ")+1:]
+		}
+		return strings.Join(lines, " ")
+	}
+
+	if got := err.Error(); got != "third: second: first: root" {
+		t.Errorf("expected newest first by default, got %q", got)
+	}
+	if got := messages(); got != "third second first root" {
+		t.Errorf("expected newest first in Details by default, got %q", got)
+	}
+
+	eg.AnnotationOrder = eg.OldestFirst
+	defer func() { eg.AnnotationOrder = eg.NewestFirst }()
+
+	if got := err.Error(); got != "first: second: third: root" {
+		t.Errorf("expected oldest first, got %q", got)
+	}
+	if got := messages(); got != "first second third root" {
+		t.Errorf("expected oldest first in Details, got %q", got)
+	}
+}
+
+func TestLocations(t *testing.T) {
+	root := eg.NoteAt(eg.Error("root"), "db.query", "db.go", 10, "querying")
+	err := eg.NoteAt(eg.Error("outer").SetCause(root), "http.serve", "http.go", 20, "serving")
+
+	locs := eg.Locations(err)
+	if len(locs) != 4 {
+		t.Fatalf("expected 4 locations, got %v", locs)
+	}
+	want := []string{"http.serve", "TestLocations", "db.query", "TestLocations"}
+	for x, l := range locs {
+		if !strings.HasSuffix(l.Function, want[x]) {
+			t.Errorf("expected location %d in %s, got %s", x, want[x], l.Function)
+		}
+	}
+	if locs[0].File != "http.go" || locs[0].Line != 20 {
+		t.Errorf("expected the outer annotation first, got %s:%d", locs[0].File, locs[0].Line)
+	}
+}
+
+func TestSetLocationOverride(t *testing.T) {
+	restore := eg.SetLocationOverride("pkg.Func", "file.go", 1)
+	err := eg.Note(eg.Note(eg.Error("root"), "first"), "second")
+	wrapped := eg.Note(errors.New("plain"), "wrapped")
+	restore()
+
+	for _, e := range []error{err, wrapped} {
+		for _, l := range eg.Locations(e) {
+			if l.Function != "pkg.Func" || l.File != "file.go" || l.Line != 1 {
+				t.Errorf("expected the overridden location, got %s@%s:%d", l.Function, l.File, l.Line)
+			}
+		}
+	}
+	if want := "[pkg.Func@file.go:1] second\n[pkg.Func@file.go:1] first\n[pkg.Func@file.go:1] root"; eg.Details(err) != want {
+		t.Errorf("expected:\n%s\ngot:\n%s", want, eg.Details(err))
+	}
+	if l := eg.Locations(eg.Error("after")); !strings.HasSuffix(l[0].Function, "TestSetLocationOverride") {
+		t.Errorf("expected the real location after restoring, got %s", l[0].Function)
+	}
+}
@@ -0,0 +1,44 @@
+package eg
+
+import (
+	"runtime"
+	"strconv"
+	"strings"
+)
+
+// TrackGoroutines, if true, records the goroutine that creates each error and
+// each annotation.  Details then marks annotations added by a different
+// goroutine than the one that created the error, such as
+// "(crossed goroutine 1->7)", to surface unexpected sharing.  Finding the
+// goroutine is slow, so it is off by default.
+var TrackGoroutines = false
+
+// goroutineID returns the ID of the current goroutine, or zero if
+// TrackGoroutines is false.
+func goroutineID() uint64 {
+	if !TrackGoroutines {
+		return 0
+	}
+	return currentGoroutine()
+}
+
+// currentGoroutine returns the ID of the current goroutine.
+func currentGoroutine() uint64 {
+	// The stack starts with "goroutine 123 [running]:".
+	var buf [64]byte
+	s := strings.TrimPrefix(string(buf[:runtime.Stack(buf[:], false)]), "goroutine ")
+	if i := strings.IndexByte(s, ' '); i >= 0 {
+		s = s[:i]
+	}
+	id, _ := strconv.ParseUint(s, 10, 64)
+	return id
+}
+
+// crossed returns a marker if an annotation added by goroutine annotator
+// crossed over from goroutine creator, or an empty string if it didn't.
+func crossed(creator, annotator uint64) string {
+	if creator == 0 || annotator == 0 || creator == annotator {
+		return ""
+	}
+	return " (crossed goroutine " + strconv.FormatUint(creator, 10) + "->" + strconv.FormatUint(annotator, 10) + ")"
+}
@@ -0,0 +1,38 @@
+package eg_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/natefinch/eg"
+)
+
+func TestTrackGoroutines(t *testing.T) {
+	eg.TrackGoroutines = true
+	defer func() { eg.TrackGoroutines = false }()
+
+	created := make(chan error)
+	go func() { created <- eg.Error("created elsewhere") }()
+	err := eg.Note(<-created, "annotated here")
+	err = eg.Note(err, "annotated here again")
+
+	lines := strings.Split(eg.Details(err), "\n")
+	for _, line := range lines[:2] {
+		if !strings.Contains(line, "annotated here") || !strings.Contains(line, " (crossed goroutine ") {
+			t.Errorf("expected crossing marker, got %q", line)
+		}
+	}
+	if strings.Contains(lines[2], "crossed") {
+		t.Errorf("expected no marker on the created error, got %q", lines[2])
+	}
+}
+
+func TestTrackGoroutinesSame(t *testing.T) {
+	eg.TrackGoroutines = true
+	defer func() { eg.TrackGoroutines = false }()
+
+	err := eg.Note(eg.Error("created here"), "annotated here")
+	if strings.Contains(eg.Details(err), "crossed") {
+		t.Errorf("expected no marker, got %q", eg.Details(err))
+	}
+}
@@ -0,0 +1,31 @@
+package eg
+
+import (
+	"crypto/rand"
+	"fmt"
+)
+
+// newID returns a random UUID-like identifier.
+func newID() string {
+	var b [16]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		return ""
+	}
+	b[6] = b[6]&0x0f | 0x40 // version 4
+	b[8] = b[8]&0x3f | 0x80 // variant 10
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:])
+}
+
+// ID returns the unique ID of the error instance at the root of err's chain,
+// assigned when it was created by Error, Mask, or Enrich, so that the same
+// error can be tracked across log lines.  The ID is kept as the error is
+// annotated or wrapped.  If no ID is found, ok will be false.
+func ID(err error) (id string, ok bool) {
+	walk(err, func(err error) bool {
+		if e, isErr := err.(*Err); isErr && e.ID != "" {
+			id, ok = e.ID, true
+		}
+		return true
+	})
+	return id, ok
+}
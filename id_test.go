@@ -0,0 +1,51 @@
+package eg_test
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"testing"
+
+	"github.com/natefinch/eg"
+)
+
+func TestIDUnique(t *testing.T) {
+	uuid := regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`)
+	seen := map[string]bool{}
+	for x := 0; x < 100; x++ {
+		id, ok := eg.ID(eg.Error("same"))
+		if !ok || !uuid.MatchString(id) {
+			t.Fatalf("expected a UUID, got %q (ok=%v)", id, ok)
+		}
+		if seen[id] {
+			t.Fatalf("expected unique IDs, got %q twice", id)
+		}
+		seen[id] = true
+	}
+}
+
+func TestIDSurvivesNote(t *testing.T) {
+	root := eg.Error("root")
+	id, _ := eg.ID(root)
+
+	err := eg.Note(fmt.Errorf("wrapped: %w", eg.Note(root, "annotated")), "outer")
+	if got, ok := eg.ID(err); !ok || got != id {
+		t.Errorf("expected ID %q, got %q (ok=%v)", id, got, ok)
+	}
+}
+
+func TestIDMissing(t *testing.T) {
+	if id, ok := eg.ID(eg.Note(errors.New("plain"), "noted")); ok {
+		t.Errorf("expected no ID, got %q", id)
+	}
+}
+
+func TestIDClone(t *testing.T) {
+	orig := eg.Error("template")
+	origID, _ := eg.ID(orig)
+	cloneID, _ := eg.ID(orig.Clone())
+
+	if cloneID == "" || cloneID == origID {
+		t.Errorf("expected clone to get its own ID, got %q and %q", origID, cloneID)
+	}
+}
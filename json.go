@@ -0,0 +1,82 @@
+package eg
+
+import (
+	"encoding/json"
+)
+
+// MaxJSONDepth is the maximum number of errors in a chain rendered by
+// MarshalJSON.  Deeper causes are replaced by a truncation marker.
+var MaxJSONDepth = 100
+
+var _ json.Marshaler = (*Err)(nil)
+
+// jsonErr is the JSON representation of an error.  It is a struct, rather than
+// a map, so that fields are always rendered in the same order; context keys
+// are sorted by encoding/json.
+type jsonErr struct {
+	Message     string                 `json:"message,omitempty"`
+	Location    *jsonLocation          `json:"location,omitempty"`
+	Annotations []jsonAnnotation       `json:"annotations,omitempty"`
+	Code        string                 `json:"code,omitempty"`
+	RequestID   string                 `json:"request_id,omitempty"`
+	Context     map[string]interface{} `json:"context,omitempty"`
+	Cause       *jsonErr               `json:"cause,omitempty"`
+	Truncated   string                 `json:"truncated,omitempty"`
+}
+
+type jsonLocation struct {
+	Function string `json:"function"`
+	File     string `json:"file"`
+	Line     int    `json:"line"`
+}
+
+type jsonAnnotation struct {
+	Message  string       `json:"message"`
+	Location jsonLocation `json:"location"`
+}
+
+// MarshalJSON implements json.Marshaler.  The error is rendered with its
+// message, location, annotations (newest first), code, request ID, context,
+// and cause.  Chains deeper than MaxJSONDepth, or that refer back to an
+// earlier error, end with a cause holding only a "truncated" reason.
+func (e *Err) MarshalJSON() ([]byte, error) {
+	if e == nil {
+		return []byte("null"), nil
+	}
+	return json.Marshal(toJSON(e, 0, map[*Err]bool{}))
+}
+
+func toJSON(err error, depth int, seen map[*Err]bool) *jsonErr {
+	if depth >= MaxJSONDepth {
+		return &jsonErr{Truncated: "max depth exceeded"}
+	}
+	e, ok := err.(*Err)
+	if !ok {
+		return &jsonErr{Message: err.Error()}
+	}
+	if e == nil {
+		return &jsonErr{}
+	}
+	if seen[e] {
+		return &jsonErr{Truncated: "cycle detected"}
+	}
+	seen[e] = true
+
+	j := &jsonErr{
+		Message:   e.Message,
+		Code:      e.Code,
+		RequestID: e.RequestID,
+		Context:   e.Context,
+	}
+	if e.Location != (location{}) {
+		j.Location = &jsonLocation{e.Location.Function, e.Location.File, e.Location.Line}
+	}
+	for x := len(e.Annotations) - 1; x >= 0; x-- {
+		a := e.Annotations[x]
+		j.Annotations = append(j.Annotations, jsonAnnotation{a.Message, jsonLocation{a.Function, a.File, a.Line}})
+	}
+	if e.CauseErr != nil {
+		j.Cause = toJSON(e.CauseErr, depth+1, seen)
+	}
+	return j
+}
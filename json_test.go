@@ -0,0 +1,104 @@
+package eg_test
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/natefinch/eg"
+)
+
+type decoded struct {
+	Message   string   `json:"message"`
+	Cause     *decoded `json:"cause"`
+	Truncated string   `json:"truncated"`
+}
+
+// last decodes b and returns the deepest error in it and the chain's length.
+func last(t *testing.T, b []byte) (*decoded, int) {
+	d := &decoded{}
+	if err := json.Unmarshal(b, d); err != nil {
+		t.Fatal(err)
+	}
+	n := 1
+	for d.Cause != nil {
+		d = d.Cause
+		n++
+	}
+	return d, n
+}
+
+func TestMarshalJSONDeepChain(t *testing.T) {
+	err := eg.Error("root")
+	for x := 0; x < 1000; x++ {
+		err = &eg.Err{Message: "layer", CauseErr: err}
+	}
+
+	b, merr := json.Marshal(err)
+	if merr != nil {
+		t.Fatal(merr)
+	}
+	d, n := last(t, b)
+	if n != eg.MaxJSONDepth+1 {
+		t.Errorf("expected chain of %d, got %d", eg.MaxJSONDepth+1, n)
+	}
+	if d.Truncated == "" {
+		t.Error("expected a truncation marker")
+	}
+}
+
+func TestMarshalJSONCycle(t *testing.T) {
+	err := eg.Error("root")
+	err.CauseErr = &eg.Err{Message: "middle", CauseErr: err}
+
+	b, merr := json.Marshal(err)
+	if merr != nil {
+		t.Fatal(merr)
+	}
+	d, n := last(t, b)
+	if n != 3 || d.Truncated != "cycle detected" {
+		t.Errorf("expected cycle to be truncated after 2 errors, got %d: %s", n, b)
+	}
+}
+
+// golden returns an error with fixed locations for golden tests.
+func golden() error {
+	e := &eg.Err{Message: "loading config", CauseErr: errors.New("file not found"), Code: "config"}
+	e.Location.Function = "main.load"
+	e.Location.File = "main.go"
+	e.Location.Line = 10
+	e.Annotate("starting", "main.start", "main.go", 20)
+	return eg.SetValue(eg.SetValue(e, attemptsKey, 3), eg.NewContextKey[string]("path"), "/etc/app")
+}
+
+func TestMarshalJSONStable(t *testing.T) {
+	first, err := json.Marshal(golden())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for x := 0; x < 10; x++ {
+		again, err := json.Marshal(golden())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(again) != string(first) {
+			t.Fatalf("expected identical output, got:\n%s\n%s", first, again)
+		}
+	}
+}
+
+func TestMarshalJSONGolden(t *testing.T) {
+	b, err := json.Marshal(golden())
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := `{"message":"loading config",` +
+		`"location":{"function":"main.load","file":"main.go","line":10},` +
+		`"annotations":[{"message":"starting","location":{"function":"main.start","file":"main.go","line":20}}],` +
+		`"code":"config",` +
+		`"context":{"attempts":3,"path":"/etc/app"},` +
+		`"cause":{"message":"file not found"}}`
+	if string(b) != expected {
+		t.Errorf("expected:\n%s\ngot:\n%s", expected, b)
+	}
+}
@@ -0,0 +1,106 @@
+package eg
+
+import (
+	"unicode/utf8"
+)
+
+// Headline returns a single human-readable message for err, suitable for
+// display to end users.  It returns the message of the outermost Err in the
+// chain, descending to the first non-empty message if the outer ones are empty.
+// For errors that are not Errs, it returns the error's Error() string.
+func Headline(err error) string {
+	headline := ""
+	walk(err, func(err error) bool {
+		e, ok := err.(*Err)
+		if !ok {
+			headline = err.Error()
+			return false
+		}
+		headline = e.Message
+		return headline == ""
+	})
+	return headline
+}
+
+// Summary returns a single line describing err by the first message rendered
+// by its Error, such as the outermost Err's newest annotation, and the message
+// of its root cause, skipping everything in between, such as
+// "can't bootstrap (root: file not found)".
+func Summary(err error) string {
+	if err == nil {
+		return ""
+	}
+	first := ""
+	if msgs := segments(err); len(msgs) > 0 {
+		first = msgs[0]
+	}
+	root := RootCause(err)
+	if root == err {
+		return first
+	}
+	msg := root.Error()
+	if e, ok := root.(*Err); ok {
+		msg = e.Message
+	}
+	return first + " (root: " + msg + ")"
+}
+
+// WithUserMessage attaches a friendly message for end users to err, separate
+// from its technical message, which is unchanged.  If err is not an Err, it is
+// wrapped in one.
+func WithUserMessage(err error, msg string) error {
+	if err == nil {
+		return nil
+	}
+	e := toErr(err, 1)
+	e.UserMessage = msg
+	return reported(e, err)
+}
+
+// UserMessage returns the user message of the nearest Err in err's chain that
+// has one.  If none is found, ok will be false.
+func UserMessage(err error) (msg string, ok bool) {
+	walk(err, func(err error) bool {
+		if e, isErr := err.(*Err); isErr && e.UserMessage != "" {
+			msg, ok = e.UserMessage, true
+		}
+		return !ok
+	})
+	return msg, ok
+}
+
+// BestMessage returns the most specific message in err's chain that is at most
+// maxLen runes long, for notifications with length limits.  That is the
+// innermost non-empty message that fits.  If none fits, the root message is
+// truncated to fit, ending with "…".
+func BestMessage(err error, maxLen int) string {
+	msgs := segments(err)
+	if len(msgs) == 0 || maxLen <= 0 {
+		return ""
+	}
+	for x := len(msgs) - 1; x >= 0; x-- {
+		if utf8.RuneCountInString(msgs[x]) <= maxLen {
+			return msgs[x]
+		}
+	}
+	root := []rune(msgs[len(msgs)-1])
+	return string(root[:maxLen-1]) + "…"
+}
+
+// MapMessages returns a copy of err's chain with fn applied to every message
+// and annotation of each Err, such as for localization.  Locations and the
+// chain's structure are unchanged, as is the original chain.  Errors that are
+// not Errs are kept as they are, along with their causes.
+func MapMessages(err error, fn func(string) string) error {
+	e, ok := err.(*Err)
+	if !ok || e == nil {
+		return err
+	}
+	c := e.Clone()
+	c.Message = fn(c.Message)
+	for x := range c.Annotations {
+		c.Annotations[x].Message = fn(c.Annotations[x].Message)
+	}
+	c.CauseErr = MapMessages(c.CauseErr, fn)
+	return c
+}
@@ -0,0 +1,130 @@
+package eg_test
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/natefinch/eg"
+)
+
+func TestHeadline(t *testing.T) {
+	err := eg.Note(eg.Note(errors.New("file not found"), "loading config"), "starting up")
+
+	if h := eg.Headline(err); h != "loading config" {
+		t.Errorf("expected %q, got %q", "loading config", h)
+	}
+}
+
+func TestHeadlineOuterEmpty(t *testing.T) {
+	inner := eg.Error("disk full")
+	err := &eg.Err{CauseErr: &eg.Err{CauseErr: inner}}
+
+	if h := eg.Headline(err); h != "disk full" {
+		t.Errorf("expected %q, got %q", "disk full", h)
+	}
+}
+
+func TestHeadlinePlain(t *testing.T) {
+	if h := eg.Headline(errors.New("plain")); h != "plain" {
+		t.Errorf("expected %q, got %q", "plain", h)
+	}
+}
+
+func TestSummary(t *testing.T) {
+	err := eg.Note(errors.New("file not found"), "loading config")
+	err = &eg.Err{Message: "starting foo", CauseErr: err}
+	err = &eg.Err{Message: "bootstrap failed", CauseErr: err}
+
+	expected := "bootstrap failed (root: file not found)"
+	if s := eg.Summary(err); s != expected {
+		t.Errorf("expected %q, got %q", expected, s)
+	}
+}
+
+func TestSummaryNoteChain(t *testing.T) {
+	err := eg.Note(eg.Note(errors.New("file not found"), "loading config"), "bootstrap failed")
+
+	expected := "bootstrap failed (root: file not found)"
+	if s := eg.Summary(err); s != expected {
+		t.Errorf("expected %q, got %q", expected, s)
+	}
+}
+
+func TestSummarySingle(t *testing.T) {
+	if s := eg.Summary(eg.Error("alone")); s != "alone" {
+		t.Errorf("expected %q, got %q", "alone", s)
+	}
+}
+
+func TestUserMessage(t *testing.T) {
+	root := eg.WithUserMessage(eg.Error("pq: connection refused"), "Please try again later.")
+	err := eg.Note(eg.Note(root, "querying"), "loading user")
+
+	msg, ok := eg.UserMessage(err)
+	if !ok || msg != "Please try again later." {
+		t.Errorf("expected user message, got %q (ok=%v)", msg, ok)
+	}
+	if err.Error() != "loading user: querying: pq: connection refused" {
+		t.Errorf("expected technical message to be unchanged, got %q", err.Error())
+	}
+}
+
+func TestUserMessageMissing(t *testing.T) {
+	if msg, ok := eg.UserMessage(eg.Error("root")); ok {
+		t.Errorf("expected no user message, got %q", msg)
+	}
+}
+
+func TestBestMessage(t *testing.T) {
+	err := eg.Note(errors.New("dial tcp 10.0.0.1:5432: connection refused"), "db down")
+	err = &eg.Err{Message: "couldn't save your order", CauseErr: err}
+
+	tests := []struct {
+		maxLen   int
+		expected string
+	}{
+		{100, "dial tcp 10.0.0.1:5432: connection refused"},
+		{30, "db down"},
+		{7, "db down"},
+		{5, "dial…"},
+	}
+	for _, test := range tests {
+		if got := eg.BestMessage(err, test.maxLen); got != test.expected {
+			t.Errorf("maxLen %d: expected %q, got %q", test.maxLen, test.expected, got)
+		}
+	}
+}
+
+func TestMapMessages(t *testing.T) {
+	root := errors.New("disk full")
+	orig := eg.Note(eg.Error("writing file").SetCause(root), "saving")
+	orig = eg.Note(eg.Error("can't save").SetCause(orig), "handling request")
+
+	mapped := eg.MapMessages(orig, strings.ToUpper)
+
+	if mapped.Error() != "HANDLING REQUEST: CAN'T SAVE: SAVING: WRITING FILE: disk full" {
+		t.Errorf("unexpected mapped error %q", mapped.Error())
+	}
+	if orig.Error() != "handling request: can't save: saving: writing file: disk full" {
+		t.Errorf("expected original to be unchanged, got %q", orig.Error())
+	}
+	if eg.Details(mapped) != upperMessages(eg.Details(orig)) {
+		t.Errorf("expected locations to be unchanged, got:\n%s", eg.Details(mapped))
+	}
+	if eg.RootCause(mapped) != root {
+		t.Error("expected structure to be preserved down to the root")
+	}
+}
+
+// upperMessages uppercases the message after the location on each line of
+// details, except for the final, plain error.
+func upperMessages(details string) string {
+	lines := strings.Split(details, "\n")
+	for x, line := range lines[:len(lines)-1] {
+		if i := strings.Index(line, "] "); i >= 0 {
+			lines[x] = line[:i+2] + strings.ToUpper(line[i+2:])
+		}
+	}
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,68 @@
+package eg
+
+import (
+	"strings"
+)
+
+// multi is an error that combines several errors.  Details renders each of
+// them in full, and errors.Is and errors.As check each of them.
+type multi []error
+
+// Error returns the combined errors' messages separated by semicolons.
+func (m multi) Error() string {
+	msgs := make([]string, len(m))
+	for x, err := range m {
+		msgs[x] = err.Error()
+	}
+	return strings.Join(msgs, "; ")
+}
+
+// Unwrap returns the combined errors.
+func (m multi) Unwrap() []error {
+	return m
+}
+
+// Details returns the full details of each combined error, each indented as
+// its own block.
+func (m multi) Details() string {
+	return joinedDetails(m)
+}
+
+// Join combines errs into a single error, like errors.Join, but one whose
+// Details shows the full details of each error rather than only its message.
+// errors.Is and errors.As check each of them.  Nil errors are dropped.  Join
+// returns nil if no errors remain, and the error itself if only one does.
+func Join(errs ...error) error {
+	var m multi
+	for _, err := range errs {
+		if err != nil {
+			m = append(m, err)
+		}
+	}
+	switch len(m) {
+	case 0:
+		return nil
+	case 1:
+		return m[0]
+	}
+	return m
+}
+
+// Errors returns an error for each of msgs, all recording the caller's
+// location, stack, and goroutine, and each with its own ID, as with Error.  It
+// returns nil if msgs is empty, a single Err for one message, and otherwise an
+// error combining an Err for each message, such as for reporting every problem
+// found while validating input.
+func Errors(msgs ...string) error {
+	if len(msgs) == 0 {
+		return nil
+	}
+	errs := make(multi, len(msgs))
+	for x, msg := range msgs {
+		errs[x] = created(newErr(1, msg))
+	}
+	if len(errs) == 1 {
+		return errs[0]
+	}
+	return errs
+}
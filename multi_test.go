@@ -0,0 +1,117 @@
+package eg_test
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/natefinch/eg"
+)
+
+func TestErrorsNone(t *testing.T) {
+	if err := eg.Errors(); err != nil {
+		t.Errorf("expected nil, got %v", err)
+	}
+}
+
+func TestErrorsOne(t *testing.T) {
+	err := eg.Errors("name is required")
+
+	e, ok := err.(*eg.Err)
+	if !ok {
+		t.Fatalf("expected an *eg.Err, got %T", err)
+	}
+	if e.Message != "name is required" || !strings.HasSuffix(e.Location.Function, "TestErrorsOne") {
+		t.Errorf("unexpected error %q created at %v", e.Message, e.Location)
+	}
+}
+
+func TestErrorsMany(t *testing.T) {
+	err := eg.Errors("name is required", "age must be positive", "email is invalid")
+
+	expected := "name is required; age must be positive; email is invalid"
+	if err.Error() != expected {
+		t.Errorf("expected %q, got %q", expected, err.Error())
+	}
+
+	if lines := strings.Split(eg.Details(err), "\n"); len(lines) != 3 {
+		t.Errorf("expected 3 lines of details, got %q", lines)
+	}
+
+	errs := err.(interface{ Unwrap() []error }).Unwrap()
+	first := errs[0].(*eg.Err).Location
+	for _, e := range errs[1:] {
+		if l := e.(*eg.Err).Location; l != first {
+			t.Errorf("expected every error to be created at %v, got %v", first, l)
+		}
+	}
+	for _, e := range errs {
+		if _, ok := eg.ID(e); !ok || e.(*eg.Err).StackTrace() == "" {
+			t.Errorf("expected each error to be built as by Error, got %#v", e)
+		}
+	}
+
+	var e *eg.Err
+	if !errors.As(err, &e) || e.Message != "name is required" {
+		t.Errorf("expected errors.As to find the first error, got %v", e)
+	}
+}
+
+func TestIsFindsSentinelInBranch(t *testing.T) {
+	sentinel := eg.Lazy("sentinel")
+	branches := []error{
+		eg.Error("first"),
+		eg.Note(fmt.Errorf("second: %w", sentinel), "annotated"),
+		eg.Error("third"),
+	}
+	err := eg.Note(errors.Join(branches...), "combined")
+
+	if !errors.Is(err, sentinel) {
+		t.Error("expected sentinel to be found in the second branch")
+	}
+
+	branches[2] = eg.Note(sentinel, "again")
+	if !errors.Is(eg.Note(errors.Join(branches...), "combined"), sentinel) {
+		t.Error("expected sentinel to be found when in several branches")
+	}
+	if errors.Is(err, eg.Lazy("sentinel")) {
+		t.Error("expected a different sentinel with the same message not to match")
+	}
+}
+
+func TestJoin(t *testing.T) {
+	plain := errors.New("disk full")
+	rich := eg.Note(eg.Error("connection refused"), "dialing db")
+	err := eg.Join(rich, nil, plain)
+
+	if err.Error() != "dialing db: connection refused; disk full" {
+		t.Errorf("unexpected Error() %q", err.Error())
+	}
+	details := eg.Details(err)
+	for _, line := range strings.Split(eg.Details(rich), "\n") {
+		if !strings.Contains(details, "\t"+line+"\n") {
+			t.Errorf("expected %q in the joined details, got:\n%s", line, details)
+		}
+	}
+	if !strings.HasSuffix(details, "\n\tdisk full") {
+		t.Errorf("expected the plain error last, got:\n%s", details)
+	}
+	if !errors.Is(err, plain) || !errors.Is(err, rich) {
+		t.Error("expected errors.Is to check every joined error")
+	}
+	var e *eg.Err
+	if !errors.As(err, &e) || e != rich {
+		t.Error("expected errors.As to find the eg error")
+	}
+}
+
+func TestJoinNil(t *testing.T) {
+	if err := eg.Join(nil, nil); err != nil {
+		t.Errorf("expected nil, got %v", err)
+	}
+	plain := errors.New("only")
+	if err := eg.Join(nil, plain); err != plain {
+		t.Errorf("expected the only error itself, got %v", err)
+	}
+}
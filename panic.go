@@ -0,0 +1,33 @@
+package eg
+
+import (
+	"fmt"
+)
+
+// FromPanic builds a single error from a value recovered from a panic and the
+// error, if any, that the request being handled had already failed with.  The
+// result wraps requestErr, is annotated with the panic value, and records the
+// stack of the panic.  It is intended for use in middleware:
+//
+//	defer func() {
+//		if r := recover(); r != nil {
+//			err = eg.FromPanic(r, err)
+//		}
+//	}()
+//
+// If recovered is nil, requestErr is returned unchanged.
+func FromPanic(recovered interface{}, requestErr error) error {
+	if recovered == nil {
+		return requestErr
+	}
+	var e *Err
+	if requestErr != nil {
+		e = wrap(requestErr, 1, "")
+	} else {
+		e = newErr(1, "recovered from panic")
+	}
+	l := locate(1)
+	e.Annotate(fmt.Sprintf("panic: %v", recovered), l.Function, l.File, l.Line)
+	e.Stack = callers(1)
+	return created(e)
+}
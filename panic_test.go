@@ -0,0 +1,48 @@
+package eg_test
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/natefinch/eg"
+)
+
+func panicky() {
+	panic("nil map")
+}
+
+func handle(requestErr error) (err error) {
+	err = requestErr
+	defer func() {
+		if r := recover(); r != nil {
+			err = eg.FromPanic(r, err)
+		}
+	}()
+	panicky()
+	return err
+}
+
+func TestFromPanic(t *testing.T) {
+	requestErr := errors.New("bad request")
+	err := handle(requestErr)
+
+	if err.Error() != "panic: nil map: bad request" {
+		t.Errorf("expected panic and request error, got %q", err.Error())
+	}
+	if !errors.Is(err, requestErr) {
+		t.Error("expected the request error to be the cause")
+	}
+	if trace := err.(*eg.Err).StackTrace(); !strings.Contains(trace, "eg_test.panicky@") {
+		t.Errorf("expected the panicking function in the stack, got:\n%s", trace)
+	}
+}
+
+func TestFromPanicWithoutError(t *testing.T) {
+	if err := handle(nil); err.Error() != "panic: nil map: recovered from panic" {
+		t.Errorf("unexpected error %q", err.Error())
+	}
+	if err := eg.FromPanic(nil, nil); err != nil {
+		t.Errorf("expected nil without a panic, got %v", err)
+	}
+}
@@ -0,0 +1,42 @@
+package eg
+
+import (
+	"sync"
+	"time"
+)
+
+// now returns the current time, as used by ShouldLog.  Tests replace it to
+// control time.
+var now = time.Now
+
+// maxLogCache is the most distinct errors ShouldLog remembers.  When it is
+// exceeded, the cache is emptied.
+const maxLogCache = 1024
+
+var logged = struct {
+	sync.Mutex
+	last map[uint64]time.Time
+}{last: map[uint64]time.Time{}}
+
+// ShouldLog reports whether err should be logged, returning true at most once
+// per window for errors that are identical: the same messages and locations
+// throughout their chains.  It is intended for sampling bursts of identical
+// errors to avoid log spam.  A nil error is never logged.
+func ShouldLog(err error, window time.Duration) bool {
+	if err == nil {
+		return false
+	}
+	fp := fingerprint(err)
+	t := now()
+
+	logged.Lock()
+	defer logged.Unlock()
+	if last, ok := logged.last[fp]; ok && t.Sub(last) < window {
+		return false
+	}
+	if len(logged.last) >= maxLogCache {
+		logged.last = map[uint64]time.Time{}
+	}
+	logged.last[fp] = t
+	return true
+}
@@ -0,0 +1,36 @@
+package eg_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/natefinch/eg"
+)
+
+func query() error {
+	return eg.Note(errors.New("connection reset"), "querying db")
+}
+
+func TestShouldLog(t *testing.T) {
+	now := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	defer eg.SetNow(func() time.Time { return now })()
+
+	if !eg.ShouldLog(query(), time.Minute) {
+		t.Error("expected the first error to be logged")
+	}
+	now = now.Add(30 * time.Second)
+	if eg.ShouldLog(query(), time.Minute) {
+		t.Error("expected an identical error within the window not to be logged")
+	}
+	if !eg.ShouldLog(eg.Note(query(), "other"), time.Minute) {
+		t.Error("expected a different error to be logged")
+	}
+	now = now.Add(time.Minute)
+	if !eg.ShouldLog(query(), time.Minute) {
+		t.Error("expected an identical error after the window to be logged")
+	}
+	if eg.ShouldLog(nil, time.Minute) {
+		t.Error("expected nil not to be logged")
+	}
+}
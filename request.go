@@ -0,0 +1,24 @@
+package eg
+
+// WithRequestID attaches a correlation or request ID to err, so it can be
+// traced across services.  If err is not an Err, it is wrapped in one.
+func WithRequestID(err error, id string) error {
+	if err == nil {
+		return nil
+	}
+	e := toErr(err, 1)
+	e.RequestID = id
+	return reported(e, err)
+}
+
+// RequestID returns the request ID of the nearest Err in err's chain that has
+// one.  If no request ID is found, ok will be false.
+func RequestID(err error) (id string, ok bool) {
+	walk(err, func(err error) bool {
+		if e, isErr := err.(*Err); isErr && e.RequestID != "" {
+			id, ok = e.RequestID, true
+		}
+		return !ok
+	})
+	return id, ok
+}
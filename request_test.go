@@ -0,0 +1,37 @@
+package eg_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/natefinch/eg"
+)
+
+func TestRequestID(t *testing.T) {
+	root := eg.WithRequestID(eg.Error("root"), "req-123")
+	err := eg.Note(eg.Note(root, "first"), "second")
+
+	id, ok := eg.RequestID(err)
+	if !ok || id != "req-123" {
+		t.Errorf("expected request id %q, got %q (ok=%v)", "req-123", id, ok)
+	}
+	if !strings.HasPrefix(eg.Details(err), "request id: req-123\n") {
+		t.Errorf("expected details to start with the request id, got %q", eg.Details(err))
+	}
+
+	found := false
+	for _, a := range err.(*eg.Err).LogValue().Group() {
+		if a.Key == "request_id" && a.Value.String() == "req-123" {
+			found = true
+		}
+	}
+	if !found {
+		t.Errorf("expected request_id in log value, got %v", err.(*eg.Err).LogValue())
+	}
+}
+
+func TestRequestIDMissing(t *testing.T) {
+	if id, ok := eg.RequestID(eg.Error("root")); ok {
+		t.Errorf("expected no request id, got %q", id)
+	}
+}
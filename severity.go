@@ -0,0 +1,104 @@
+package eg
+
+// Severity is how serious an error is.  Greater values are more severe.  The
+// zero value means no severity has been set.
+type Severity int
+
+// Severities, from least to most severe.
+const (
+	SeverityDebug Severity = iota + 1
+	SeverityInfo
+	SeverityWarn
+	SeverityError
+	SeverityFatal
+)
+
+var severityNames = map[Severity]string{
+	SeverityDebug: "DEBUG",
+	SeverityInfo:  "INFO",
+	SeverityWarn:  "WARN",
+	SeverityError: "ERROR",
+	SeverityFatal: "FATAL",
+}
+
+// String returns the severity's name, such as "WARN".
+func (s Severity) String() string {
+	return severityNames[s]
+}
+
+// WithSeverity sets the severity of err.  If err is not an Err, it is wrapped
+// in one.
+func WithSeverity(err error, s Severity) error {
+	if err == nil {
+		return nil
+	}
+	e := toErr(err, 1)
+	e.Severity = s
+	return reported(e, err)
+}
+
+// OnWarn, if not nil, is called with every error passed to Warn, such as for
+// logging warnings or counting them.  Like OnError, it should be cheap and
+// must not retain the Err.
+var OnWarn func(*Err)
+
+// Warn marks err as a warning that was handled without failing, setting its
+// severity to SeverityWarn and passing it to OnWarn, if set.  It returns the
+// error, so that callers may still propagate it.  If err is not an Err, it is
+// wrapped in one.
+//
+//	if err := refreshCache(); err != nil {
+//		eg.Warn(err) // serve stale data
+//	}
+func Warn(err error) error {
+	if err == nil {
+		return nil
+	}
+	e := toErr(err, 1)
+	e.Severity = SeverityWarn
+	reported(e, err)
+	if OnWarn != nil {
+		OnWarn(e)
+	}
+	return e
+}
+
+// SeverityOf returns the severity of the nearest Err in err's chain that has
+// one, or zero if there is none.
+func SeverityOf(err error) Severity {
+	var s Severity
+	walk(err, func(err error) bool {
+		if e, ok := err.(*Err); ok {
+			s = e.Severity
+		}
+		return s == 0
+	})
+	return s
+}
+
+// MostSevere returns the non-nil error in errs with the highest severity.  If
+// several are equally severe, the first is returned.
+func MostSevere(errs ...error) error {
+	var worst error
+	var max Severity
+	for _, err := range errs {
+		if err == nil {
+			continue
+		}
+		if s := SeverityOf(err); worst == nil || s > max {
+			worst, max = err, s
+		}
+	}
+	return worst
+}
+
+// defaultMessage returns msg, or a label for err's severity if msg is empty.
+func defaultMessage(err error, msg string) string {
+	if msg != "" {
+		return msg
+	}
+	if s := SeverityOf(err); s != 0 {
+		return "[" + s.String() + "]"
+	}
+	return msg
+}
@@ -0,0 +1,65 @@
+package eg_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/natefinch/eg"
+)
+
+func TestMostSevere(t *testing.T) {
+	debug := eg.WithSeverity(eg.Error("debug"), eg.SeverityDebug)
+	fatal := eg.Note(eg.WithSeverity(errors.New("fatal"), eg.SeverityFatal), "annotated")
+	warn := eg.WithSeverity(eg.Error("warn"), eg.SeverityWarn)
+	fatal2 := eg.WithSeverity(eg.Error("fatal2"), eg.SeverityFatal)
+
+	if err := eg.MostSevere(debug, nil, warn, fatal, fatal2); err != fatal {
+		t.Errorf("expected %v, got %v", fatal, err)
+	}
+	if err := eg.MostSevere(errors.New("plain"), debug); err != debug {
+		t.Errorf("expected %v, got %v", debug, err)
+	}
+	if err := eg.MostSevere(nil, nil); err != nil {
+		t.Errorf("expected nil, got %v", err)
+	}
+}
+
+func TestSeverityString(t *testing.T) {
+	if s := eg.SeverityWarn.String(); s != "WARN" {
+		t.Errorf("expected %q, got %q", "WARN", s)
+	}
+}
+
+func TestNoteSeverityLabel(t *testing.T) {
+	err := eg.WithSeverity(errors.New("disk almost full"), eg.SeverityWarn)
+	err = eg.Note(err, "")
+
+	if err.Error() != "[WARN]: disk almost full" {
+		t.Errorf("expected severity label, got %q", err.Error())
+	}
+	if err := eg.Note(errors.New("plain"), ""); err.Error() != "plain" {
+		t.Errorf("expected no label without a severity, got %q", err.Error())
+	}
+}
+
+func TestWarn(t *testing.T) {
+	var warned []*eg.Err
+	eg.OnWarn = func(e *eg.Err) { warned = append(warned, e) }
+	defer func() { eg.OnWarn = nil }()
+
+	cause := errors.New("cache unreachable")
+	err := eg.Warn(cause)
+
+	if eg.SeverityOf(err) != eg.SeverityWarn {
+		t.Errorf("expected WARN, got %v", eg.SeverityOf(err))
+	}
+	if len(warned) != 1 || warned[0] != err {
+		t.Errorf("expected the sink to receive the error once, got %v", warned)
+	}
+	if !errors.Is(err, cause) {
+		t.Error("expected the returned error to wrap the original")
+	}
+	if eg.Warn(nil) != nil || len(warned) != 1 {
+		t.Error("expected nil not to be warned about")
+	}
+}
@@ -0,0 +1,26 @@
+package eg
+
+import (
+	"log/slog"
+)
+
+var _ slog.LogValuer = (*Err)(nil)
+
+// LogValue implements slog.LogValuer, so errors logged with log/slog are
+// rendered as a group holding the error's message along with its code and
+// request ID, if any.  The group is flat, so slog's TextHandler renders it as
+// readable pairs such as err.msg="..." err.code=NOT_FOUND rather than as one
+// quoted blob.
+func (e *Err) LogValue() slog.Value {
+	if e == nil {
+		return slog.GroupValue()
+	}
+	attrs := []slog.Attr{slog.String("msg", e.Error())}
+	if code, ok := Code(e); ok {
+		attrs = append(attrs, slog.String("code", code))
+	}
+	if id, ok := RequestID(e); ok {
+		attrs = append(attrs, slog.String("request_id", id))
+	}
+	return slog.GroupValue(attrs...)
+}
@@ -0,0 +1,30 @@
+package eg_test
+
+import (
+	"bytes"
+	"log/slog"
+	"strings"
+	"testing"
+
+	"github.com/natefinch/eg"
+)
+
+func TestLogValueTextHandler(t *testing.T) {
+	buf := &bytes.Buffer{}
+	logger := slog.New(slog.NewTextHandler(buf, &slog.HandlerOptions{
+		ReplaceAttr: func(groups []string, a slog.Attr) slog.Attr {
+			if a.Key == slog.TimeKey && len(groups) == 0 {
+				return slog.Attr{}
+			}
+			return a
+		},
+	}))
+
+	err := eg.Note(eg.WithRequestID(eg.MaskCode(eg.Error("no rows"), "NOT_FOUND", "loading user"), "req-1"), "handling request")
+	logger.Error("failed", "err", err)
+
+	want := `level=ERROR msg=failed err.msg="handling request: loading user: no rows" err.code=NOT_FOUND err.request_id=req-1`
+	if got := strings.TrimSpace(buf.String()); got != want {
+		t.Errorf("expected:\n%s\ngot:\n%s", want, got)
+	}
+}
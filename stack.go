@@ -0,0 +1,146 @@
+package eg
+
+import (
+	"fmt"
+	"runtime"
+	"strings"
+)
+
+// maxStackDepth is the maximum number of frames recorded in an error's stack.
+const maxStackDepth = 64
+
+// StackPolicy controls which errors capture a full stack when created.  Every
+// error records the single location where it was created regardless.
+type StackPolicy int
+
+const (
+	// RootOnly captures a stack only for errors at the root of a chain, such
+	// as those created by Error and Mask, and not for errors that wrap another
+	// error.
+	RootOnly StackPolicy = iota
+
+	// EveryLayer captures a stack for every error created, including those
+	// that wrap another error.
+	EveryLayer
+
+	// NoStack never captures a stack.
+	NoStack
+)
+
+// Stacks is the StackPolicy used when creating errors.
+var Stacks = RootOnly
+
+// capture returns the stack of the caller depth levels above the caller of
+// capture, if the StackPolicy calls for it.  root reports whether the error
+// being created is the root of its chain.
+func capture(depth int, root bool) stack {
+	if Stacks == NoStack || (Stacks == RootOnly && !root) {
+		return nil
+	}
+	return callers(depth + 1)
+}
+
+// StackCapturer captures the program counters of the stack for a new error.
+// Capture is passed the skip to hand to runtime.Callers, called directly from
+// Capture, so that the first frame returned is where the error was created.
+// The returned slice is copied as soon as Capture returns, so implementations
+// may reuse buffers.
+type StackCapturer interface {
+	Capture(skip int) []uintptr
+}
+
+// Capturer is the StackCapturer used to record stacks.  A nil Capturer
+// records no stacks.
+var Capturer StackCapturer = runtimeCapturer{}
+
+// runtimeCapturer captures up to maxStackDepth frames with runtime.Callers.
+type runtimeCapturer struct{}
+
+// Capture implements StackCapturer.
+func (runtimeCapturer) Capture(skip int) []uintptr {
+	pcs := make([]uintptr, maxStackDepth)
+	n := runtime.Callers(skip, pcs)
+	return pcs[:n]
+}
+
+// Deepest returns the error among errs with the most frames in a captured
+// stack, which is usually the most useful one to log in full when several
+// errors are collected.  An error's frames are those of the longest stack in
+// its chain.  Ties go to the earliest error, and nil errors are skipped.  If
+// none of errs is non-nil, Deepest returns nil.
+func Deepest(errs ...error) error {
+	var deepest error
+	most := -1
+	for _, err := range errs {
+		if err == nil {
+			continue
+		}
+		n := 0
+		walk(err, func(err error) bool {
+			if e, ok := err.(*Err); ok && len(e.Stack) > n {
+				n = len(e.Stack)
+			}
+			return true
+		})
+		if n > most {
+			deepest, most = err, n
+		}
+	}
+	return deepest
+}
+
+// stack is the program counters of a captured stack, innermost call first.
+// They are only resolved to functions, files, and lines when the stack is
+// rendered, so that creating an error doesn't pay for symbolization.
+type stack []uintptr
+
+// callers returns the stack of the caller depth levels above the caller of
+// callers, using the configured Capturer.
+func callers(depth int) stack {
+	if Capturer == nil {
+		return nil
+	}
+	pcs := Capturer.Capture(depth + 3)
+	if len(pcs) == 0 {
+		return nil
+	}
+	return append(stack(nil), pcs...)
+}
+
+// frames returns the locations of the stack, innermost call first.
+func (s stack) frames() []location {
+	if len(s) == 0 {
+		return nil
+	}
+	frames := runtime.CallersFrames(s)
+	locs := make([]location, 0, len(s))
+	for {
+		f, more := frames.Next()
+		locs = append(locs, location{f.Function, f.File, f.Line})
+		if !more {
+			break
+		}
+	}
+	return locs
+}
+
+// String returns the stack one frame per line.  Consecutive identical frames,
+// such as those produced by recursion, are collapsed into a single line
+// followed by the number of times the frame repeats.
+func (s stack) String() string {
+	locs := s.frames()
+	lines := []string{}
+	for x := 0; x < len(locs); {
+		n := 1
+		for x+n < len(locs) && locs[x+n] == locs[x] {
+			n++
+		}
+		if n > 1 {
+			lines = append(lines, fmt.Sprintf("%s (x%d)", locs[x], n))
+		} else {
+			lines = append(lines, locs[x].String())
+		}
+		x += n
+	}
+	return strings.Join(lines, "\n")
+}
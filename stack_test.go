@@ -0,0 +1,155 @@
+package eg_test
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/natefinch/eg"
+)
+
+func recurse(depth int) *eg.Err {
+	if depth == 0 {
+		return eg.Error("bottom")
+	}
+	return recurse(depth - 1)
+}
+
+func TestStackTraceCollapsesRecursion(t *testing.T) {
+	trace := recurse(10).StackTrace()
+
+	lines := strings.Split(trace, "\n")
+	if !strings.Contains(lines[0], "recurse") {
+		t.Errorf("expected first frame to be the creating function, got %q", lines[0])
+	}
+	if !strings.Contains(lines[1], "recurse") || !strings.HasSuffix(lines[1], " (x10)") {
+		t.Errorf("expected recursive frames to be collapsed, got %q", lines[1])
+	}
+	if strings.Count(trace, "recurse") != 2 {
+		t.Errorf("expected exactly two recurse lines, got:\n%s", trace)
+	}
+}
+
+// stacks returns the number of Errs in err's chain that captured a stack.
+func stacks(err error) int {
+	n := 0
+	for ok := true; err != nil && ok; err, ok = eg.Cause(err) {
+		if e, isErr := err.(*eg.Err); isErr && len(e.Stack) > 0 {
+			n++
+		}
+	}
+	return n
+}
+
+// layered returns an error with a root and three wrapping layers.
+func layered() error {
+	err := eg.Note(eg.Error("root"), "annotated")
+	for x := 0; x < 3; x++ {
+		err = eg.Note(fmt.Errorf("layer %d: %w", x, err), "noted")
+	}
+	return err
+}
+
+func TestStackPolicy(t *testing.T) {
+	defer func(old eg.StackPolicy) { eg.Stacks = old }(eg.Stacks)
+
+	for policy, expected := range map[eg.StackPolicy]int{
+		eg.RootOnly:   1,
+		eg.EveryLayer: 4,
+		eg.NoStack:    0,
+	} {
+		eg.Stacks = policy
+		if n := stacks(layered()); n != expected {
+			t.Errorf("policy %d: expected %d stacks, got %d", policy, expected, n)
+		}
+	}
+}
+
+func TestEveryLayerNoteAt(t *testing.T) {
+	defer func(old eg.StackPolicy) { eg.Stacks = old }(eg.Stacks)
+	eg.Stacks = eg.EveryLayer
+
+	err := eg.NoteAt(errors.New("undefined variable"), "render", "index.tmpl", 12, "rendering")
+	trace := err.(*eg.Err).StackTrace()
+	if !strings.Contains(trace, "eg_test.TestEveryLayerNoteAt@") {
+		t.Errorf("expected a stack from the caller of NoteAt, got:\n%s", trace)
+	}
+}
+
+// markerCapturer records a marker frame ahead of the real stack.
+type markerCapturer struct{}
+
+func (markerCapturer) Capture(skip int) []uintptr {
+	pcs := make([]uintptr, 32)
+	n := runtime.Callers(skip, pcs[1:])
+	// The frames are treated as return addresses, so point just past the
+	// function's entry.  Unlike a PC captured inside it, this doesn't depend
+	// on inlining.
+	pcs[0] = reflect.ValueOf(markerFunc).Pointer() + 1
+	return pcs[:n+1]
+}
+
+func markerFunc() {}
+
+func TestCustomCapturer(t *testing.T) {
+	defer func(c eg.StackCapturer) { eg.Capturer = c }(eg.Capturer)
+	eg.Capturer = markerCapturer{}
+
+	trace := eg.Error("boom").StackTrace()
+	marker := strings.Index(trace, "eg_test.markerFunc@")
+	creator := strings.Index(trace, "eg_test.TestCustomCapturer@")
+	if marker < 0 || creator < marker {
+		t.Errorf("expected the marker frame followed by the creating function, got:\n%s", trace)
+	}
+
+	eg.Capturer = nil
+	if trace := eg.Error("boom").StackTrace(); trace != "" {
+		t.Errorf("expected no stack without a capturer, got:\n%s", trace)
+	}
+}
+
+// reusingCapturer captures every stack into the same buffer.
+type reusingCapturer struct {
+	pcs []uintptr
+}
+
+func (c *reusingCapturer) Capture(skip int) []uintptr {
+	return c.pcs[:runtime.Callers(skip, c.pcs)]
+}
+
+func stackFromHelper() *eg.Err {
+	return eg.Error("helper")
+}
+
+func TestCapturerReusesBuffer(t *testing.T) {
+	defer func(c eg.StackCapturer) { eg.Capturer = c }(eg.Capturer)
+	eg.Capturer = &reusingCapturer{pcs: make([]uintptr, 32)}
+
+	// Stacks are resolved to frames only when rendered, so the first must not
+	// be overwritten by the second capture.
+	first := eg.Error("first")
+	second := stackFromHelper()
+
+	if trace := first.StackTrace(); strings.Contains(trace, "stackFromHelper") {
+		t.Errorf("expected the first stack to be kept, got:\n%s", trace)
+	}
+	if trace := second.StackTrace(); !strings.Contains(trace, "eg_test.stackFromHelper@") {
+		t.Errorf("expected the helper in the second stack, got:\n%s", trace)
+	}
+}
+
+func TestDeepest(t *testing.T) {
+	shallow := recurse(1)
+	deep := eg.Note(recurse(5), "wrapped")
+	tie := recurse(5)
+
+	if got := eg.Deepest(nil, shallow, errors.New("no stack"), deep, tie); got != deep {
+		t.Errorf("expected the error with the deepest stack, got %v", got)
+	}
+	if got := eg.Deepest(nil, nil); got != nil {
+		t.Errorf("expected nil for only nil errors, got %v", got)
+	}
+}
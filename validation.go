@@ -0,0 +1,103 @@
+package eg
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// ErrValidation is matched by errors.Is for every ValidationError.
+var ErrValidation = errors.New("validation failed")
+
+// ValidationError is an Err that collects problems with individual fields,
+// such as when validating a form.  Its Error summarizes how many problems were
+// found, and its Details lists each field's problems.
+type ValidationError struct {
+	*Err
+	fields map[string][]string
+}
+
+// Validation returns a new ValidationError with the given message, recording
+// the caller's location.  Add problems to it with AddField.
+func Validation(msg string, args ...interface{}) *ValidationError {
+	return &ValidationError{Err: created(newErr(1, msg, args...)), fields: map[string][]string{}}
+}
+
+// AddField records msg as a problem with field.  A field may have several
+// problems.  It returns the error itself.
+func (v *ValidationError) AddField(field, msg string) *ValidationError {
+	if v.fields == nil {
+		v.fields = map[string][]string{}
+	}
+	v.fields[field] = append(v.fields[field], msg)
+	return v
+}
+
+// Annotate annotates the underlying Err, as with (*Err).Annotate, but returns
+// the ValidationError itself, so that noting it keeps its fields, its summary,
+// and its match with ErrValidation.
+func (v *ValidationError) Annotate(msg, function, file string, line int) error {
+	v.Err.Annotate(msg, function, file, line)
+	return v
+}
+
+// Fields returns a copy of the problems recorded for each field.
+func (v *ValidationError) Fields() map[string][]string {
+	fields := make(map[string][]string, len(v.fields))
+	for field, msgs := range v.fields {
+		fields[field] = append([]string(nil), msgs...)
+	}
+	return fields
+}
+
+// count returns the total number of problems recorded.
+func (v *ValidationError) count() int {
+	n := 0
+	for _, msgs := range v.fields {
+		n += len(msgs)
+	}
+	return n
+}
+
+// Error returns the error's message followed by the number of problems found,
+// such as "invalid signup: 3 field errors".  The underlying Err's annotations
+// and cause are rendered around them, as by (*Err).Error.
+func (v *ValidationError) Error() string {
+	summary := fmt.Sprintf("%d field errors", v.count())
+	if v.count() == 1 {
+		summary = "1 field error"
+	}
+	if v.Err == nil {
+		return summary
+	}
+	e := *v.Err
+	if e.Message == "" {
+		e.Message = summary
+	} else {
+		e.Message += Separator + summary
+	}
+	return e.render()
+}
+
+// Details returns the details of the underlying Err followed by one line per
+// problem, sorted by field.
+func (v *ValidationError) Details() string {
+	names := make([]string, 0, len(v.fields))
+	for field := range v.fields {
+		names = append(names, field)
+	}
+	sort.Strings(names)
+	lines := []string{v.Err.Details()}
+	for _, field := range names {
+		for _, msg := range v.fields[field] {
+			lines = append(lines, "\t"+field+": "+msg)
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// Is reports whether target is ErrValidation.
+func (v *ValidationError) Is(target error) bool {
+	return target == ErrValidation
+}
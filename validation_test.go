@@ -0,0 +1,92 @@
+package eg_test
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/natefinch/eg"
+)
+
+func TestValidationError(t *testing.T) {
+	v := eg.Validation("invalid signup")
+	v.AddField("email", "required").AddField("age", "must be positive").AddField("email", "must contain @")
+
+	want := map[string][]string{
+		"email": {"required", "must contain @"},
+		"age":   {"must be positive"},
+	}
+	if !reflect.DeepEqual(v.Fields(), want) {
+		t.Errorf("expected fields %v, got %v", want, v.Fields())
+	}
+	if v.Error() != "invalid signup: 3 field errors" {
+		t.Errorf("unexpected Error() %q", v.Error())
+	}
+
+	lines := strings.Split(v.Details(), "\n")
+	if len(lines) != 4 || !strings.HasSuffix(lines[0], " invalid signup") {
+		t.Fatalf("expected the error's line and three field lines, got:\n%s", v.Details())
+	}
+	if got := strings.Join(lines[1:], "\n"); got != "\tage: must be positive\n\temail: required\n\temail: must contain @" {
+		t.Errorf("expected sorted field lines, got:\n%s", got)
+	}
+}
+
+func TestValidationErrorIs(t *testing.T) {
+	v := eg.Validation("invalid signup").AddField("email", "required")
+	err := eg.Note(errors.Join(errors.New("rate limited"), v), "handling signup")
+
+	if !errors.Is(err, eg.ErrValidation) {
+		t.Error("expected errors.Is to find the validation error")
+	}
+	var got *eg.ValidationError
+	if !errors.As(err, &got) || got != v {
+		t.Error("expected errors.As to find the validation error")
+	}
+	if !strings.Contains(err.Error(), "invalid signup: 1 field error") {
+		t.Errorf("expected the summary in Error(), got %q", err.Error())
+	}
+}
+
+func TestValidationErrorNoted(t *testing.T) {
+	v := eg.Validation("invalid signup").AddField("email", "required").AddField("age", "must be positive")
+	err := eg.Note(v, "handling signup")
+
+	if !errors.Is(err, eg.ErrValidation) {
+		t.Error("expected errors.Is to match after noting")
+	}
+	if err.Error() != "handling signup: invalid signup: 2 field errors" {
+		t.Errorf("expected the summary after noting, got %q", err.Error())
+	}
+	lines := strings.Split(eg.Details(err), "\n")
+	if len(lines) != 4 || !strings.HasSuffix(lines[0], " handling signup") {
+		t.Fatalf("expected the annotation, the error, and two field lines, got:\n%s", eg.Details(err))
+	}
+	if got := strings.Join(lines[2:], "\n"); got != "\tage: must be positive\n\temail: required" {
+		t.Errorf("expected the field lines after noting, got:\n%s", got)
+	}
+}
+
+func TestValidationErrorLiteral(t *testing.T) {
+	v := &eg.ValidationError{Err: eg.Error("invalid signup")}
+	v.AddField("email", "required")
+
+	if v.Error() != "invalid signup: 1 field error" {
+		t.Errorf("unexpected Error() %q", v.Error())
+	}
+}
+
+func TestValidationErrorCause(t *testing.T) {
+	defer func(sep string) { eg.Separator = sep }(eg.Separator)
+	eg.Separator = " - "
+
+	v := eg.Validation("invalid signup").AddField("email", "required")
+	v.SetCause(errors.New("db down"))
+	err := eg.Note(v, "handling signup")
+
+	expected := "handling signup - invalid signup - 1 field error: db down"
+	if err.Error() != expected {
+		t.Errorf("expected %q, got %q", expected, err.Error())
+	}
+}